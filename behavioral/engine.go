@@ -88,6 +88,26 @@ func NewEngine() *Engine {
 	}
 }
 
+// Connect the engine to a chat model and a helper for side tasks like kaomoji; pass a helper
+// wrapped by Core.ScheduledProvider at PriorityBackground so it queues behind the user's requests
+func (e *Engine) SetProviders(chat, helper providers.AIProvider) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.aiProvider = chat
+	e.kaomojiProvider = providers.NewKaomojiProvider(helper)
+	if e.memoryProvider == nil {
+		e.memoryProvider = providers.NewMemoryProvider()
+	}
+}
+
+// Report whether SetProviders has given the engine a chat model
+func (e *Engine) Connected() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.aiProvider != nil
+}
+
 // Start the behavioral engine
 func (e *Engine) Start(ctx context.Context) error {
 	// Initialize any background processes
@@ -116,6 +136,10 @@ func (e *Engine) ProcessResponse(ctx context.Context, input string) (*Response,
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if e.aiProvider == nil {
+		return nil, fmt.Errorf("behavioral engine has no AI provider")
+	}
+
 	// Store the interaction as a memory
 	e.storeMemory(input, "user_input")
 
@@ -0,0 +1,54 @@
+package ai
+
+import (
+	"context"
+
+	"nero/providers"
+)
+
+// Adapt the scripted fake provider to the router's Provider interface
+type FakeProvider struct {
+	fake *providers.FakeProvider
+}
+
+func NewFakeProvider(fake *providers.FakeProvider) *FakeProvider {
+	return &FakeProvider{fake: fake}
+}
+
+func (f *FakeProvider) Chat(ctx context.Context, messages []Message, stream chan<- string) error {
+	defer close(stream)
+
+	return f.fake.ChatStream(ctx, toProviderMessages(messages), &providers.ChatOptions{Stream: true}, func(chunk providers.StreamChunk) {
+		if chunk.Content == "" || chunk.IsThought {
+			return
+		}
+		select {
+		case stream <- chunk.Content:
+		case <-ctx.Done():
+		}
+	})
+}
+
+func (f *FakeProvider) ChatStructured(ctx context.Context, messages []Message, format *providers.ResponseFormat) (string, error) {
+	response, err := f.fake.Chat(ctx, toProviderMessages(messages), &providers.ChatOptions{ResponseFormat: format})
+	if err != nil {
+		return "", err
+	}
+	return response.Content, nil
+}
+
+func (f *FakeProvider) GetModelSize() ModelSize {
+	return ModelLarge
+}
+
+func (f *FakeProvider) IsLocal() bool {
+	return true
+}
+
+func toProviderMessages(messages []Message) []providers.Message {
+	converted := make([]providers.Message, len(messages))
+	for i, msg := range messages {
+		converted[i] = providers.Message{Role: msg.Role, Content: msg.Content}
+	}
+	return converted
+}
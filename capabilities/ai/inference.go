@@ -10,6 +10,10 @@ import (
 	"os"
 	"strings"
 	"sync"
+
+	"nero/providers"
+	"nero/providers/credentials"
+	"nero/providers/sse"
 )
 
 type ModelSize string
@@ -26,6 +30,12 @@ type Provider interface {
 	IsLocal() bool
 }
 
+// Provider that can constrain its answer to JSON, used for helper-model parsing
+type StructuredProvider interface {
+	Provider
+	ChatStructured(ctx context.Context, messages []Message, format *providers.ResponseFormat) (string, error)
+}
+
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
@@ -35,12 +45,16 @@ type Router struct {
 	providers   map[string]Provider
 	helperModel Provider
 	mainModel   Provider
+	usage       UsageRecorder
+	acquire     Acquirer
 	mutex       sync.RWMutex
 }
 
-func NewRouter() *Router {
+// Create a router; providers registered with it report token usage to recorder, which may be nil
+func NewRouter(recorder UsageRecorder) *Router {
 	return &Router{
 		providers: make(map[string]Provider),
+		usage:     recorder,
 	}
 }
 
@@ -48,6 +62,9 @@ func (r *Router) RegisterProvider(name string, provider Provider) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	if reporter, ok := provider.(usageReporter); ok {
+		reporter.SetUsageRecorder(r.usage)
+	}
 	r.providers[name] = provider
 
 	// Auto-assign based on model size
@@ -63,16 +80,23 @@ func (r *Router) RegisterProvider(name string, provider Provider) {
 	}
 }
 
+// Queue model calls through acquire; helper calls run in the background behind the main model's
+func (r *Router) SetScheduler(acquire Acquirer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.acquire = acquire
+}
+
 func (r *Router) GetHelperModel() Provider {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	return r.helperModel
+	return schedule(r.helperModel, r.acquire, true)
 }
 
 func (r *Router) GetMainModel() Provider {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	return r.mainModel
+	return schedule(r.mainModel, r.acquire, false)
 }
 
 func (r *Router) SetMainModel(name string) error {
@@ -93,6 +117,8 @@ type OllamaProvider struct {
 	baseURL   string
 	modelName string
 	modelSize ModelSize
+	transport *providers.Transport
+	usage     UsageRecorder
 }
 
 func NewOllamaProvider(baseURL, modelName string, size ModelSize) *OllamaProvider {
@@ -100,9 +126,14 @@ func NewOllamaProvider(baseURL, modelName string, size ModelSize) *OllamaProvide
 		baseURL:   baseURL,
 		modelName: modelName,
 		modelSize: size,
+		transport: providers.NewTransport("ollama", providers.LocalTimeout),
 	}
 }
 
+func (o *OllamaProvider) SetUsageRecorder(recorder UsageRecorder) {
+	o.usage = recorder
+}
+
 func (o *OllamaProvider) Chat(ctx context.Context, messages []Message, stream chan<- string) error {
 	defer close(stream)
 
@@ -121,12 +152,15 @@ func (o *OllamaProvider) Chat(ctx context.Context, messages []Message, stream ch
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := o.transport.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	var content strings.Builder
+	var usage *providers.Usage
+
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -140,9 +174,10 @@ func (o *OllamaProvider) Chat(ctx context.Context, messages []Message, stream ch
 		}
 
 		if msg, ok := response["message"].(map[string]interface{}); ok {
-			if content, ok := msg["content"].(string); ok {
+			if text, ok := msg["content"].(string); ok {
+				content.WriteString(text)
 				select {
-				case stream <- content:
+				case stream <- text:
 				case <-ctx.Done():
 					return ctx.Err()
 				}
@@ -150,11 +185,58 @@ func (o *OllamaProvider) Chat(ctx context.Context, messages []Message, stream ch
 		}
 
 		if done, ok := response["done"].(bool); ok && done {
+			usage = ollamaUsage(response)
 			break
 		}
 	}
 
-	return scanner.Err()
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	recordUsage(o.usage, "ollama", o.modelName, usage, messages, content.String())
+	return nil
+}
+
+func (o *OllamaProvider) ChatStructured(ctx context.Context, messages []Message, format *providers.ResponseFormat) (string, error) {
+	reqBody := map[string]interface{}{
+		"model":    o.modelName,
+		"messages": messages,
+		"stream":   false,
+		"format":   format.Ollama(),
+	}
+
+	jsonData, _ := json.Marshal(reqBody)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/chat", strings.NewReader(string(jsonData)))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.transport.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Message         Message `json:"message"`
+		PromptEvalCount int     `json:"prompt_eval_count"`
+		EvalCount       int     `json:"eval_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	recordUsage(o.usage, "ollama", o.modelName, &providers.Usage{
+		PromptTokens:     response.PromptEvalCount,
+		CompletionTokens: response.EvalCount,
+		TotalTokens:      response.PromptEvalCount + response.EvalCount,
+	}, messages, response.Message.Content)
+
+	return response.Message.Content, nil
 }
 
 func (o *OllamaProvider) GetModelSize() ModelSize {
@@ -172,6 +254,8 @@ type CloudProvider struct {
 	baseURL   string
 	modelName string
 	modelSize ModelSize
+	transport *providers.Transport
+	usage     UsageRecorder
 }
 
 func NewCloudProvider(name, apiKey, baseURL, modelName string, size ModelSize) *CloudProvider {
@@ -181,16 +265,22 @@ func NewCloudProvider(name, apiKey, baseURL, modelName string, size ModelSize) *
 		baseURL:   baseURL,
 		modelName: modelName,
 		modelSize: size,
+		transport: providers.NewTransport(name, providers.DefaultTimeout),
 	}
 }
 
+func (c *CloudProvider) SetUsageRecorder(recorder UsageRecorder) {
+	c.usage = recorder
+}
+
 func (c *CloudProvider) Chat(ctx context.Context, messages []Message, stream chan<- string) error {
 	defer close(stream)
 
 	reqBody := map[string]interface{}{
-		"model":    c.modelName,
-		"messages": messages,
-		"stream":   true,
+		"model":          c.modelName,
+		"messages":       messages,
+		"stream":         true,
+		"stream_options": map[string]interface{}{"include_usage": true}, // Final chunk carries token counts
 	}
 
 	jsonData, _ := json.Marshal(reqBody)
@@ -203,15 +293,18 @@ func (c *CloudProvider) Chat(ctx context.Context, messages []Message, stream cha
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.transport.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	reader := bufio.NewReader(resp.Body)
+	var content strings.Builder
+	var usage *providers.Usage
+
+	decoder := sse.NewDecoder(resp.Body)
 	for {
-		line, err := reader.ReadString('\n')
+		event, err := decoder.Next()
 		if err == io.EOF {
 			break
 		}
@@ -219,27 +312,22 @@ func (c *CloudProvider) Chat(ctx context.Context, messages []Message, stream cha
 			return err
 		}
 
-		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
+		if event.Data == "[DONE]" {
 			break
 		}
 
 		var response map[string]interface{}
-		if err := json.Unmarshal([]byte(data), &response); err != nil {
+		if err := json.Unmarshal([]byte(event.Data), &response); err != nil {
 			continue
 		}
 
 		if choices, ok := response["choices"].([]interface{}); ok && len(choices) > 0 {
 			if choice, ok := choices[0].(map[string]interface{}); ok {
 				if delta, ok := choice["delta"].(map[string]interface{}); ok {
-					if content, ok := delta["content"].(string); ok {
+					if text, ok := delta["content"].(string); ok {
+						content.WriteString(text)
 						select {
-						case stream <- content:
+						case stream <- text:
 						case <-ctx.Done():
 							return ctx.Err()
 						}
@@ -247,11 +335,64 @@ func (c *CloudProvider) Chat(ctx context.Context, messages []Message, stream cha
 				}
 			}
 		}
+
+		// Sent in a final chunk with no choices when include_usage is set
+		if raw, ok := response["usage"].(map[string]interface{}); ok {
+			prompt, _ := raw["prompt_tokens"].(float64)
+			completion, _ := raw["completion_tokens"].(float64)
+			usage = &providers.Usage{
+				PromptTokens:     int(prompt),
+				CompletionTokens: int(completion),
+				TotalTokens:      int(prompt + completion),
+			}
+		}
 	}
 
+	recordUsage(c.usage, c.name, c.modelName, usage, messages, content.String())
 	return nil
 }
 
+func (c *CloudProvider) ChatStructured(ctx context.Context, messages []Message, format *providers.ResponseFormat) (string, error) {
+	reqBody := map[string]interface{}{
+		"model":           c.modelName,
+		"messages":        messages,
+		"response_format": format.OpenAI(),
+	}
+
+	jsonData, _ := json.Marshal(reqBody)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", strings.NewReader(string(jsonData)))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.transport.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+		Usage *cloudUsage `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no response from %s", c.name)
+	}
+
+	recordUsage(c.usage, c.name, c.modelName, response.Usage.usage(), messages, response.Choices[0].Message.Content)
+	return response.Choices[0].Message.Content, nil
+}
+
 func (c *CloudProvider) GetModelSize() ModelSize {
 	return c.modelSize
 }
@@ -260,9 +401,20 @@ func (c *CloudProvider) IsLocal() bool {
 	return false
 }
 
-// LoadProviders initializes all available providers
-func LoadProviders() *Router {
-	router := NewRouter()
+// LoadProviders initializes all available providers, reporting their usage to recorder
+func LoadProviders(recorder UsageRecorder) *Router {
+	router := NewRouter(recorder)
+
+	// A scripted fake provider replaces the real ones for tests and demos
+	if path := os.Getenv(providers.FakeScriptEnv); path != "" {
+		fake, err := providers.NewFakeProviderFromFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Fake provider disabled: %v\n", err)
+			return router
+		}
+		router.RegisterProvider("fake", NewFakeProvider(fake))
+		return router
+	}
 
 	// Try to load Ollama models
 	if err := loadOllamaModels(router); err == nil {
@@ -277,7 +429,8 @@ func LoadProviders() *Router {
 
 func loadOllamaModels(router *Router) error {
 	// Check if Ollama is running by listing available models
-	models, err := getOllamaModels("http://localhost:11434")
+	host := providers.OllamaHost()
+	models, err := getOllamaModels(host)
 	if err != nil {
 		return err
 	}
@@ -286,7 +439,7 @@ func loadOllamaModels(router *Router) error {
 	helperModels := []string{"qwen2.5:0.5b", "smollm2:135m", "phi4:mini", "gemma2:2b"}
 	for _, model := range helperModels {
 		if contains(models, model) {
-			router.RegisterProvider("ollama-helper", NewOllamaProvider("http://localhost:11434", model, ModelHelper))
+			router.RegisterProvider("ollama-helper", NewOllamaProvider(host, model, ModelHelper))
 			break
 		}
 	}
@@ -295,14 +448,14 @@ func loadOllamaModels(router *Router) error {
 	mainModels := []string{"qwen2.5:7b", "llama3.2:3b", "phi3.5:3.8b", "gemma2:9b", "llama3.1:8b"}
 	for _, model := range mainModels {
 		if contains(models, model) {
-			router.RegisterProvider("ollama-main", NewOllamaProvider("http://localhost:11434", model, ModelLarge))
+			router.RegisterProvider("ollama-main", NewOllamaProvider(host, model, ModelLarge))
 			return nil
 		}
 	}
 
 	// If no main model found but we have any model, use the first one
 	if len(models) > 0 {
-		router.RegisterProvider("ollama-main", NewOllamaProvider("http://localhost:11434", models[0], ModelLarge))
+		router.RegisterProvider("ollama-main", NewOllamaProvider(host, models[0], ModelLarge))
 	}
 
 	return nil
@@ -353,27 +506,19 @@ func contains(slice []string, item string) bool {
 
 func loadCloudProviders(router *Router) {
 	// Load API keys
-	if apiKey := loadAPIKey("openai.key"); apiKey != "" {
+	if apiKey := credentials.Lookup("openai"); apiKey != "" {
 		router.RegisterProvider("openai", NewCloudProvider("openai", apiKey, "https://api.openai.com/v1", "gpt-4o-mini", ModelLarge))
 	}
 
-	if apiKey := loadAPIKey("groq.key"); apiKey != "" {
+	if apiKey := credentials.Lookup("groq"); apiKey != "" {
 		router.RegisterProvider("groq", NewCloudProvider("groq", apiKey, "https://api.groq.com/openai/v1", "llama-3.1-8b-instant", ModelLarge))
 	}
 
-	if apiKey := loadAPIKey("gemini.key"); apiKey != "" {
+	if apiKey := credentials.Lookup("gemini"); apiKey != "" {
 		// Gemini uses different API format, would need separate provider
 	}
 }
 
-func loadAPIKey(filename string) string {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(string(data))
-}
-
 func testOllamaModel(baseURL, model string) bool {
 	req, err := http.NewRequest("POST", baseURL+"/api/generate", strings.NewReader(`{"model":"`+model+`","prompt":"test","stream":false}`))
 	if err != nil {
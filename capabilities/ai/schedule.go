@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"nero/providers"
+)
+
+// Wait for a turn on a provider, returning a func that ends it; background calls yield to the user's.
+// nero.go plugs in the kernel scheduler so helper models queue behind the main model
+type Acquirer func(ctx context.Context, provider string, background bool) (func(), error)
+
+// Route a provider's calls through an Acquirer
+type scheduledProvider struct {
+	Provider
+	key        string
+	background bool
+	acquire    Acquirer
+}
+
+// Wrap provider so its calls wait their turn, or return it as is without a scheduler
+func schedule(provider Provider, acquire Acquirer, background bool) Provider {
+	if provider == nil || acquire == nil {
+		return provider
+	}
+	return &scheduledProvider{Provider: provider, key: scheduleKey(provider), background: background, acquire: acquire}
+}
+
+// Queue calls per server: the helper and main Ollama models share one
+func scheduleKey(provider Provider) string {
+	switch p := provider.(type) {
+	case *OllamaProvider:
+		return "ollama"
+	case *CloudProvider:
+		return p.name
+	}
+	return fmt.Sprintf("%T", provider)
+}
+
+func (p *scheduledProvider) Chat(ctx context.Context, messages []Message, stream chan<- string) error {
+	release, err := p.acquire(ctx, p.key, p.background)
+	if err != nil {
+		close(stream)
+		return err
+	}
+	defer release()
+
+	return p.Provider.Chat(ctx, messages, stream)
+}
+
+func (p *scheduledProvider) ChatStructured(ctx context.Context, messages []Message, format *providers.ResponseFormat) (string, error) {
+	structured, ok := p.Provider.(StructuredProvider)
+	if !ok {
+		return "", fmt.Errorf("helper model does not support structured output")
+	}
+
+	release, err := p.acquire(ctx, p.key, p.background)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return structured.ChatStructured(ctx, messages, format)
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"nero/providers"
 )
 
 type StreamChunk struct {
@@ -155,7 +157,6 @@ func (rp *ResponseParser) ShouldSaveToMemory(response string) bool {
 
 	prompt := fmt.Sprintf(`<instruction>
 Analyze if this response contains information worth saving to user memory.
-Only respond "YES" or "NO" - no explanation.
 
 Response to analyze: "%s"
 
@@ -171,24 +172,17 @@ Do NOT save:
 - General knowledge or explanations
 </instruction>`, response)
 
-	messages := []Message{
-		{Role: "user", Content: prompt},
-	}
-
-	stream := make(chan string, 10)
 	ctx, cancel := context.WithTimeout(rp.ctx, 5*time.Second)
 	defer cancel()
 
-	if err := rp.helperModel.Chat(ctx, messages, stream); err != nil {
+	result, err := chatJSON[struct {
+		Save bool `json:"save"`
+	}](ctx, rp.helperModel, prompt, saveDecisionFormat)
+	if err != nil {
 		return false // Fail-safe
 	}
 
-	result := ""
-	for chunk := range stream {
-		result += chunk
-	}
-
-	return strings.Contains(strings.ToUpper(strings.TrimSpace(result)), "YES")
+	return result.Save
 }
 
 func (rp *ResponseParser) ExtractMemoryContent(response string) string {
@@ -201,26 +195,59 @@ Extract only the key information worth saving to memory from this response.
 Be concise but preserve important details.
 
 Response: "%s"
-
-Output only the extracted key information:
 </instruction>`, response)
 
-	messages := []Message{
-		{Role: "user", Content: prompt},
-	}
-
-	stream := make(chan string, 100)
 	ctx, cancel := context.WithTimeout(rp.ctx, 10*time.Second)
 	defer cancel()
 
-	if err := rp.helperModel.Chat(ctx, messages, stream); err != nil {
+	result, err := chatJSON[struct {
+		Memory string `json:"memory"`
+	}](ctx, rp.helperModel, prompt, memoryContentFormat)
+	if err != nil || strings.TrimSpace(result.Memory) == "" {
 		return response // Fallback
 	}
 
-	result := ""
-	for chunk := range stream {
-		result += chunk
+	return strings.TrimSpace(result.Memory)
+}
+
+// Schemas for the helper model's answers
+var (
+	saveDecisionFormat = providers.SchemaFormat("save_decision", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"save": map[string]interface{}{"type": "boolean"},
+		},
+		"required": []string{"save"},
+	})
+
+	memoryContentFormat = providers.SchemaFormat("memory_content", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"memory": map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"memory"},
+	})
+)
+
+// Ask a structured helper model for JSON matching the format and decode it into T
+func chatJSON[T any](ctx context.Context, helper Provider, prompt string, format *providers.ResponseFormat) (T, error) {
+	var result T
+
+	structured, ok := helper.(StructuredProvider)
+	if !ok {
+		return result, fmt.Errorf("helper model does not support structured output")
+	}
+
+	messages := []providers.Message{
+		{Role: "system", Content: format.Instruction()},
+		{Role: "user", Content: prompt},
 	}
 
-	return strings.TrimSpace(result)
+	return providers.CompleteJSON[T](ctx, func(ctx context.Context, messages []providers.Message) (string, error) {
+		converted := make([]Message, 0, len(messages))
+		for _, msg := range messages {
+			converted = append(converted, Message{Role: msg.Role, Content: msg.Content})
+		}
+		return structured.ChatStructured(ctx, converted, format)
+	}, messages, format)
 }
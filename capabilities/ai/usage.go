@@ -0,0 +1,59 @@
+package ai
+
+import (
+	"nero/providers"
+)
+
+// Receive token usage for a completed call; nero.go appends it to the ledger /usage reads
+type UsageRecorder func(provider, model string, usage *providers.Usage, prompt, completion string)
+
+// Implemented by providers that report usage once the router hands them a recorder
+type usageReporter interface {
+	SetUsageRecorder(recorder UsageRecorder)
+}
+
+// Record a completed call; usage is nil when the server reported none, and calls are dropped without a recorder
+func recordUsage(recorder UsageRecorder, provider, model string, usage *providers.Usage, messages []Message, completion string) {
+	if recorder == nil {
+		return
+	}
+
+	prompt := ""
+	for _, msg := range messages {
+		prompt += msg.Content
+	}
+
+	recorder(provider, model, usage, prompt, completion)
+}
+
+// Read Ollama's prompt_eval_count and eval_count
+func ollamaUsage(response map[string]interface{}) *providers.Usage {
+	prompt, _ := response["prompt_eval_count"].(float64)
+	completion, _ := response["eval_count"].(float64)
+	if prompt+completion == 0 {
+		return nil
+	}
+	return &providers.Usage{
+		PromptTokens:     int(prompt),
+		CompletionTokens: int(completion),
+		TotalTokens:      int(prompt + completion),
+	}
+}
+
+// OpenAI-style usage block
+type cloudUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *cloudUsage) usage() *providers.Usage {
+	if u == nil {
+		return nil
+	}
+	return &providers.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"nero/providers/credentials"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// Run `nero auth set|list|remove`, managing keys in the encrypted credential store
+func RunAuth(args []string) error {
+	if len(args) == 0 {
+		printAuthUsage()
+		return nil
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: nero auth set <provider> [key]")
+		}
+		return authSet(args[1], args[2:])
+	case "list", "ls":
+		return authList()
+	case "remove", "rm":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: nero auth remove <provider>")
+		}
+		return authRemove(args[1])
+	case "help", "-h", "--help":
+		printAuthUsage()
+		return nil
+	default:
+		return fmt.Errorf("unknown auth command %q - try: nero auth help", args[0])
+	}
+}
+
+func printAuthUsage() {
+	color.New(color.FgCyan).Printf(`🔐 Credential store (%s)
+
+  nero auth set <provider> [key]   Store a key (prompts when key is omitted)
+  nero auth list                   Show stored keys, masked
+  nero auth remove <provider>      Delete a stored key
+
+Providers: openai, anthropic, gemini, groq, or any provider name from config.json.
+Set %s to unlock the store without a prompt.
+`, credentials.DefaultPath(), credentials.PassphraseEnv)
+}
+
+// Open the default store, asking for the passphrase twice when it is being created
+func openStore() (*credentials.Store, error) {
+	path := credentials.DefaultPath()
+	creating := !credentials.Exists(path)
+	if creating {
+		color.New(color.FgYellow).Printf("Creating credential store at %s\n", path)
+	}
+
+	passphrase, err := credentials.Passphrase(creating)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.Open(path, passphrase)
+}
+
+func authSet(name string, args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	key := ""
+	if len(args) > 0 {
+		key = args[0]
+	} else if key, err = readKey(name); err != nil {
+		return err
+	}
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+
+	if err := store.Set(name, key); err != nil {
+		return err
+	}
+
+	color.New(color.FgGreen).Printf("✅ Stored key for %s\n", name)
+	if _, err := os.Stat(name + ".key"); err == nil {
+		color.New(color.FgYellow).Printf("💡 %s.key is still in this directory - delete it so it doesn't get committed\n", name)
+	}
+	return nil
+}
+
+func authList() error {
+	if !credentials.Exists(credentials.DefaultPath()) {
+		color.New(color.FgHiBlack).Println("No credentials stored yet - add one with: nero auth set <provider>")
+		return nil
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	names := store.Names()
+	if len(names) == 0 {
+		color.New(color.FgHiBlack).Println("No credentials stored")
+		return nil
+	}
+
+	for _, name := range names {
+		key, _ := store.Get(name)
+		fmt.Printf("  %-12s %s", name, maskKey(key))
+
+		envVar := strings.ToUpper(name) + "_API_KEY"
+		if os.Getenv(envVar) != "" {
+			color.New(color.FgHiBlack).Printf("  (overridden by %s)", envVar)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func authRemove(name string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	if err := store.Remove(name); err != nil {
+		return err
+	}
+
+	color.New(color.FgGreen).Printf("✅ Removed key for %s\n", name)
+	return nil
+}
+
+// Read a key without echoing it, or from stdin when piped
+func readKey(name string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("no key given for %s", name)
+		}
+		return line, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "API key for %s: ", name)
+	key, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(key), err
+}
+
+// Show just enough of a key to tell keys apart
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("•", len(key))
+	}
+	return key[:4] + strings.Repeat("•", 8) + key[len(key)-4:]
+}
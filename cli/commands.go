@@ -1,9 +1,19 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"nero/behavioral"
+	"nero/kernel"
+	"nero/providers"
 
 	"github.com/fatih/color"
 )
@@ -22,10 +32,17 @@ func (c *HelpCommand) Execute(args []string, ctx *CommandContext) error {
 🗣️  Chat Commands:
   /mood <state>     Set emotional state (happy, sad, excited, grumpy)
   /status           Show system and mood status
+  /usage [days]     Show token usage and estimated cost
+  /cache [cmd]      Cache responses: on, off or clear
+  /models [name]    List models offered by providers
+  /model <p>/<m>    Switch provider and model at runtime
+  /mode [mode]      Ask several providers: race, hedge, compare or single
+  /route <message>  Show which provider a message would be sent to, and why
 
 💻 System Commands:
   /run <command>    Execute system command or script
   /open <app>       Open application (notepad, calculator, etc.)
+  /agent <task>     Let Nero handle a task using files, commands and apps
 
 🏃 Control:
   /exit, /quit      Exit gracefully
@@ -34,6 +51,7 @@ func (c *HelpCommand) Execute(args []string, ctx *CommandContext) error {
 💡 Pro Tips:
   • Talk naturally - Nero understands context!
   • Use #terminal, #screen, #code for resource access
+  • Attach images with #image:./shot.png
   • Nero remembers your conversations across sessions
 
 Example: "Nero, please help me open VS Code" or "/run git status"
@@ -50,20 +68,90 @@ func (c *StatusCommand) Description() string { return "Show system status" }
 func (c *StatusCommand) Usage() string       { return "/status" }
 
 func (c *StatusCommand) Execute(args []string, ctx *CommandContext) error {
-	status := `
+	core := ctx.Interface.core
+
+	color.New(color.FgGreen).Print(`
 📊 System Status:
 
 🧠 Memory: Active and persistent
 🎭 Behavioral Engine: Operational
-🤖 AI Provider: Connected
 💾 Session Storage: Available
 
-Nero Status: Ready to assist (with attitude!) 💜
-`
-	color.New(color.FgGreen).Print(status)
+🤖 AI Providers:
+`)
+	for _, health := range core.ProviderHealth() {
+		active := " "
+		if health.Provider == core.GetActiveProvider() {
+			active = "*"
+		}
+		fmt.Printf("  %s %s\n", active, formatProviderHealth(health))
+		fmt.Printf("      %s\n", formatQueue(core.QueueStats(health.Provider)))
+	}
+
+	if streams := core.ActiveStreams(); len(streams) > 0 {
+		color.New(color.FgGreen).Print("\n📡 Active Streams:\n")
+		for _, stream := range streams {
+			fmt.Printf("  %s\n", formatStream(stream))
+		}
+	}
+
+	color.New(color.FgGreen).Print("\nNero Status: Ready to assist (with attitude!) 💜\n")
 	return nil
 }
 
+// Render a provider's running calls and queue depth by priority
+func formatQueue(stats kernel.QueueStats) string {
+	line := fmt.Sprintf("%d/%d running, %d queued", stats.Running, stats.Limit, stats.Depth())
+	if stats.Depth() == 0 {
+		return line
+	}
+
+	var classes []string
+	for _, priority := range []kernel.Priority{kernel.PriorityInteractive, kernel.PriorityBackground, kernel.PriorityBatch} {
+		if count := stats.Queued[priority]; count > 0 {
+			classes = append(classes, fmt.Sprintf("%d %s", count, priority))
+		}
+	}
+	return line + " (" + strings.Join(classes, ", ") + ")"
+}
+
+// Render an active stream's provider, age and progress
+func formatStream(stream kernel.StreamInfo) string {
+	tokens := fmt.Sprintf("%d tokens", stream.Tokens)
+	if stream.Estimated {
+		tokens = "~" + tokens
+	}
+	return fmt.Sprintf("%s  %-12s %s  %s  %d listening", stream.ID, stream.Provider, stream.Elapsed.Round(time.Second), tokens, stream.Subscribers)
+}
+
+// Render one provider's health on a single line
+func formatProviderHealth(health kernel.ProviderHealth) string {
+	icon := "🟢"
+	switch {
+	case !health.Available || health.State == kernel.CircuitOpen:
+		icon = "🔴"
+	case health.State == kernel.CircuitHalfOpen || health.ErrorRate > 0:
+		icon = "🟡"
+	}
+
+	line := fmt.Sprintf("%s %-12s circuit %-9s", icon, health.Provider, health.State)
+	if !health.Available {
+		line += "  unreachable"
+	}
+	if health.Requests == 0 {
+		return line + "  no requests yet"
+	}
+
+	line += fmt.Sprintf("  %4.0f%% errors  %s avg", health.ErrorRate*100, health.Latency.Round(time.Millisecond))
+	if !health.LastSuccess.IsZero() {
+		line += fmt.Sprintf("  last ok %s ago", time.Since(health.LastSuccess).Round(time.Second))
+	}
+	if health.State != kernel.CircuitClosed && health.LastError != "" {
+		line += "\n      " + health.LastError
+	}
+	return line
+}
+
 // Change Nero's mood
 type MoodCommand struct{}
 
@@ -144,6 +232,418 @@ func (c *OpenCommand) Execute(args []string, ctx *CommandContext) error {
 	return nil
 }
 
+// Let Nero complete a task using system tools
+type AgentCommand struct{}
+
+func (c *AgentCommand) Name() string        { return "agent" }
+func (c *AgentCommand) Description() string { return "Let Nero use system tools to complete a task" }
+func (c *AgentCommand) Usage() string       { return "/agent <task>" }
+
+func (c *AgentCommand) Execute(args []string, ctx *CommandContext) error {
+	if len(args) == 0 {
+		return fmt.Errorf("please describe the task")
+	}
+
+	cli := ctx.Interface
+	agent := kernel.NewAgent(cli.core, kernel.SystemTools(cli.systemProvider))
+	agent.SetConfirm(func(call providers.ToolCall) bool {
+		return cli.confirm("  ⚠️  Allow Nero to " + describeToolCall(call) + "?")
+	})
+
+	req := &kernel.AIRequest{
+		Messages: []providers.Message{
+			{Role: "user", Content: strings.Join(args, " ")},
+		},
+		SystemPrompt: cli.behavior.GetPersonalityPrompt(),
+		Temperature:  0.3,
+	}
+
+	color.New(color.FgMagenta).Println("Nero: *cracks knuckles* Fine, I'll handle it myself...")
+
+	// Ctrl+C stops the agent instead of the whole program
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	response, err := agent.Run(runCtx, req, func(step kernel.AgentStep) {
+		color.New(color.FgHiBlack).Printf("  %d. ", step.Step)
+		color.New(color.FgYellow).Printf("🔧 %s", step.Call.Name)
+		color.New(color.FgHiBlack).Printf("(%s)", formatToolArgs(step.Call.Arguments))
+
+		if step.Error != nil {
+			color.New(color.FgRed).Printf(" ✗ %v\n", step.Error)
+			return
+		}
+		color.New(color.FgHiBlack, color.Faint).Printf(" → %d bytes in %v\n", len(step.Result), step.Duration.Truncate(time.Millisecond))
+	})
+	if errors.Is(err, context.Canceled) {
+		fmt.Printf("\n💭 Agent interrupted. Continue chatting...\n")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cli.displayResponse(&behavioral.Response{
+		Text: response.Content,
+		Tone: cli.behavior.GetCurrentMood(),
+	})
+	return nil
+}
+
+// Spell out a tool call in full for confirmation, so nothing the user approves is truncated
+func describeToolCall(call providers.ToolCall) string {
+	switch call.Name {
+	case "run_command":
+		command := fmt.Sprint(call.Arguments["command"])
+		if args, ok := call.Arguments["args"].([]interface{}); ok {
+			for _, arg := range args {
+				command += " " + strconv.Quote(fmt.Sprint(arg))
+			}
+		}
+		return "run: " + command
+	case "write_file":
+		content, _ := call.Arguments["content"].(string)
+		return fmt.Sprintf("write %d bytes to %v (replacing it if it exists)", len(content), call.Arguments["path"])
+	case "open_app":
+		return fmt.Sprintf("open %v", call.Arguments["name"])
+	}
+	return fmt.Sprintf("call %s(%s)", call.Name, formatToolArgs(call.Arguments))
+}
+
+// Render tool arguments compactly for the trace
+func formatToolArgs(args map[string]interface{}) string {
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		value := fmt.Sprint(args[key])
+		if len(value) > 40 {
+			value = value[:37] + "..."
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", key, value))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// List models offered by the registered providers
+type ModelsCommand struct{}
+
+func (c *ModelsCommand) Name() string        { return "models" }
+func (c *ModelsCommand) Description() string { return "List available models" }
+func (c *ModelsCommand) Usage() string       { return "/models [provider]" }
+
+func (c *ModelsCommand) Execute(args []string, ctx *CommandContext) error {
+	core := ctx.Interface.core
+
+	names := core.GetAvailableProviders()
+	if len(args) > 0 {
+		names = []string{args[0]}
+	}
+	sort.Strings(names)
+
+	active := core.GetActiveProvider()
+	for _, name := range names {
+		listCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		models, err := core.ListModels(listCtx, name)
+		cancel()
+
+		current := core.GetModel(name)
+		header := fmt.Sprintf("\n%s (current: %s)", name, current)
+		if name == active {
+			header += " ← active"
+		}
+		color.New(color.FgCyan, color.Bold).Println(header)
+
+		if err != nil {
+			if len(args) > 0 {
+				return err
+			}
+			color.New(color.FgRed).Printf("  ✗ %v\n", err)
+			continue
+		}
+
+		for _, model := range models {
+			marker := "  "
+			if providers.ModelMatches(model.Name, current) {
+				marker = "● "
+			}
+
+			line := marker + model.Name
+			if model.DisplayName != "" && model.DisplayName != model.Name {
+				line += color.New(color.FgHiBlack).Sprintf("  %s", model.DisplayName)
+			}
+			if model.Size > 0 {
+				line += color.New(color.FgHiBlack).Sprintf("  %.1f GB", float64(model.Size)/1e9)
+			}
+			fmt.Println("  " + line)
+		}
+	}
+
+	color.New(color.FgHiBlack).Println("\nSwitch with /model <provider>/<name>")
+	return nil
+}
+
+// Switch the active provider and model at runtime
+type ModelCommand struct{}
+
+func (c *ModelCommand) Name() string        { return "model" }
+func (c *ModelCommand) Description() string { return "Switch provider and model" }
+func (c *ModelCommand) Usage() string       { return "/model <provider>/<name>" }
+
+func (c *ModelCommand) Execute(args []string, ctx *CommandContext) error {
+	core := ctx.Interface.core
+
+	if len(args) == 0 {
+		active := core.GetActiveProvider()
+		color.New(color.FgCyan).Printf("🤖 Current model: %s/%s\n", active, core.GetModel(active))
+		return nil
+	}
+
+	// Split on the first slash only: model names may contain slashes themselves (e.g. OpenRouter)
+	name, model, found := strings.Cut(args[0], "/")
+	if !found || name == "" || model == "" {
+		return fmt.Errorf("usage: %s", c.Usage())
+	}
+
+	switchCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := core.SwitchModel(switchCtx, name, model); err != nil {
+		return err
+	}
+	if ctx.Interface.behavior.Connected() {
+		ctx.Interface.connectBehavior()
+	}
+
+	color.New(color.FgGreen).Printf("✅ Switched to %s/%s\n", name, core.GetModel(name))
+	color.New(color.FgMagenta).Println("Nero: *sigh* New brain, same attitude. Don't expect me to be nicer.")
+	return nil
+}
+
+// Show token usage and estimated cost
+type UsageCommand struct{}
+
+func (c *UsageCommand) Name() string        { return "usage" }
+func (c *UsageCommand) Description() string { return "Show token usage and estimated cost" }
+func (c *UsageCommand) Usage() string       { return "/usage [days]" }
+
+func (c *UsageCommand) Execute(args []string, ctx *CommandContext) error {
+	days, err := ParseUsageDays(args)
+	if err != nil {
+		return err
+	}
+
+	report, err := FormatUsage(ctx.Interface.core.Ledger(), days)
+	if err != nil {
+		return err
+	}
+
+	color.New(color.FgCyan).Print(report)
+	return nil
+}
+
+// Control the response cache
+type CacheCommand struct{}
+
+func (c *CacheCommand) Name() string        { return "cache" }
+func (c *CacheCommand) Description() string { return "Turn the response cache on or off, or clear it" }
+func (c *CacheCommand) Usage() string       { return "/cache [on|off|clear]" }
+
+func (c *CacheCommand) Execute(args []string, ctx *CommandContext) error {
+	core := ctx.Interface.core
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "on":
+			core.SetCacheEnabled(true)
+			color.New(color.FgGreen).Println("✅ Caching all responses")
+			return nil
+		case "off":
+			core.SetCacheEnabled(false)
+			color.New(color.FgYellow).Println("Caching only requests that opt in")
+			return nil
+		case "clear":
+			if err := core.Cache().Clear(); err != nil {
+				return err
+			}
+			color.New(color.FgGreen).Println("✅ Response cache cleared")
+			return nil
+		default:
+			return fmt.Errorf("usage: %s", c.Usage())
+		}
+	}
+
+	entries, size := core.Cache().Stats()
+	color.New(color.FgCyan).Printf("💾 Response cache: %d entries, %.1f KB on disk (%s)\n", entries, float64(size)/1024, kernel.DefaultCacheDir())
+	return nil
+}
+
+// Choose how chat requests are spread across providers
+type ModeCommand struct{}
+
+func (c *ModeCommand) Name() string        { return "mode" }
+func (c *ModeCommand) Description() string { return "Race, hedge or compare requests across providers" }
+func (c *ModeCommand) Usage() string       { return "/mode [single|race|hedge|compare]" }
+
+func (c *ModeCommand) Execute(args []string, ctx *CommandContext) error {
+	cli := ctx.Interface
+
+	if len(args) == 0 {
+		mode := string(cli.requestMode)
+		if mode == "" {
+			mode = "single"
+		}
+		color.New(color.FgCyan).Printf("🔀 Request mode: %s\n", mode)
+		return nil
+	}
+
+	switch mode := kernel.RequestMode(strings.ToLower(args[0])); mode {
+	case "single", kernel.ModeSingle:
+		cli.requestMode = kernel.ModeSingle
+		color.New(color.FgGreen).Println("✅ Asking one provider at a time")
+	case kernel.ModeRace:
+		cli.requestMode = mode
+		color.New(color.FgGreen).Println("✅ Racing providers; the first complete answer wins")
+	case kernel.ModeHedge:
+		cli.requestMode = mode
+		color.New(color.FgGreen).Println("✅ Hedging slow providers with a backup")
+	case kernel.ModeCompare:
+		cli.requestMode = mode
+		color.New(color.FgGreen).Println("✅ Showing every provider's answer")
+	default:
+		return fmt.Errorf("usage: %s", c.Usage())
+	}
+	return nil
+}
+
+// Explain how a message would be routed without sending it
+type RouteCommand struct{}
+
+func (c *RouteCommand) Name() string        { return "route" }
+func (c *RouteCommand) Description() string { return "Show which provider a message would be sent to" }
+func (c *RouteCommand) Usage() string       { return "/route <message>" }
+
+func (c *RouteCommand) Execute(args []string, ctx *CommandContext) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", c.Usage())
+	}
+
+	routeCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	decision, err := ctx.Interface.core.Route(routeCtx, &kernel.AIRequest{
+		Messages: []providers.Message{{Role: "user", Content: strings.Join(args, " ")}},
+	})
+	if decision != nil {
+		color.New(color.FgCyan).Printf("🧭 Task: %s (%s)\n", decision.Task, decision.Classifier)
+	}
+	if err != nil {
+		return err
+	}
+
+	target := decision.Provider
+	if decision.Model != "" {
+		target += "/" + decision.Model
+	}
+	color.New(color.FgGreen).Printf("   → %s: %s\n", target, decision.Reason)
+
+	names := make([]string, 0, len(decision.Rejected))
+	for name := range decision.Rejected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		color.New(color.Faint).Printf("   ✗ %s: %s\n", name, decision.Rejected[name])
+	}
+	return nil
+}
+
+// Read the optional day count for /usage, defaulting to a week
+func ParseUsageDays(args []string) (int, error) {
+	if len(args) == 0 {
+		return 7, nil
+	}
+
+	days, err := strconv.Atoi(args[0])
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("days must be a positive number")
+	}
+	return days, nil
+}
+
+// Render today's and this week's totals plus per-day and per-model breakdowns
+func FormatUsage(ledger *kernel.Ledger, days int) (string, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	summary, err := ledger.Summarize(today.AddDate(0, 0, -(days - 1)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	week, err := ledger.Summarize(today.AddDate(0, 0, -6))
+	if err != nil {
+		return "", fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("\n📈 Token Usage:\n")
+
+	todayTotals := week.ByDay[today.Format("2006-01-02")]
+	if todayTotals == nil {
+		todayTotals = &kernel.UsageTotals{}
+	}
+	fmt.Fprintf(&b, "  Today:     %s\n", formatUsageTotals(todayTotals))
+	fmt.Fprintf(&b, "  This week: %s\n", formatUsageTotals(&week.Total))
+
+	if summary.Total.Requests == 0 {
+		fmt.Fprintf(&b, "\n  No requests in the last %d days\n", days)
+		return b.String(), nil
+	}
+
+	fmt.Fprintf(&b, "\n📅 Last %d days:\n", days)
+	for i := days - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i).Format("2006-01-02")
+		if totals, exists := summary.ByDay[day]; exists {
+			fmt.Fprintf(&b, "  %s  %s\n", day, formatUsageTotals(totals))
+		}
+	}
+
+	b.WriteString("\n🤖 By model:\n")
+	models := make([]string, 0, len(summary.ByModel))
+	for model := range summary.ByModel {
+		models = append(models, model)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		if summary.ByModel[models[i]].Cost != summary.ByModel[models[j]].Cost {
+			return summary.ByModel[models[i]].Cost > summary.ByModel[models[j]].Cost
+		}
+		return models[i] < models[j]
+	})
+	for _, model := range models {
+		fmt.Fprintf(&b, "  %-40s %s\n", model, formatUsageTotals(summary.ByModel[model]))
+	}
+
+	fmt.Fprintf(&b, "\n  Total: %s\n", formatUsageTotals(&summary.Total))
+	if summary.Total.Estimated || week.Total.Estimated {
+		b.WriteString("  ~ marks token counts estimated from text length\n")
+	}
+	return b.String(), nil
+}
+
+// Render usage totals on one line
+func formatUsageTotals(totals *kernel.UsageTotals) string {
+	approx := ""
+	if totals.Estimated {
+		approx = "~"
+	}
+	return fmt.Sprintf("%4d req  %s%d in / %s%d out tokens  %s$%.4f",
+		totals.Requests, approx, totals.PromptTokens, approx, totals.CompletionTokens, approx, totals.Cost)
+}
+
 // Exit the application
 type ExitCommand struct{}
 
@@ -166,6 +666,13 @@ func (cli *Interface) registerDefaultCommands() {
 		&MoodCommand{},
 		&RunCommand{},
 		&OpenCommand{},
+		&AgentCommand{},
+		&UsageCommand{},
+		&CacheCommand{},
+		&ModelsCommand{},
+		&ModelCommand{},
+		&ModeCommand{},
+		&RouteCommand{},
 		&ExitCommand{},
 	}
 
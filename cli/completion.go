@@ -35,7 +35,7 @@ func NewSyntaxHighlighter() *SyntaxHighlighter {
 	return &SyntaxHighlighter{
 		extensions: []string{"nero", "system", "dev", "code"},
 		commands:   []string{"/help", "/clear", "/status", "/quit", "/exit", "/config", "/spin", "/reset"},
-		resources:  []string{"#terminal", "#screen", "#code", "#memory", "#config"},
+		resources:  []string{"#terminal", "#screen", "#code", "#memory", "#config", "#image:"},
 		keywords:   []string{"full", "lite", "true", "false"},
 	}
 }
@@ -226,6 +226,9 @@ func (sh *SyntaxHighlighter) getCommandColor(cmd string) Color {
 }
 
 func (sh *SyntaxHighlighter) getResourceColor(res string) Color {
+	if strings.HasPrefix(res, "#image:") {
+		return Cyan
+	}
 	for _, known := range sh.resources {
 		if res == known {
 			switch known {
@@ -267,7 +270,7 @@ func NewAutoCompleter() *AutoCompleter {
 	return &AutoCompleter{
 		extensions: []string{"@nero", "@system", "@dev", "@code"},
 		commands:   []string{"/help", "/clear", "/status", "/quit", "/exit"},
-		resources:  []string{"#terminal", "#screen", "#code", "#memory", "#config"},
+		resources:  []string{"#terminal", "#screen", "#code", "#memory", "#config", "#image:"},
 		history:    make([]string, 0),
 	}
 }
@@ -3,12 +3,15 @@ package cli
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 	"time"
 
 	"nero/behavioral"
+	"nero/config"
 	"nero/kernel"
 	"nero/providers"
 
@@ -23,7 +26,7 @@ type Completer struct {
 // Create a new autocompletion handler
 func NewCompleter() *Completer {
 	return &Completer{
-		commands: []string{"help", "status", "mood", "run", "open", "exit", "quit", "provider", "stream", "thoughts"},
+		commands: []string{"help", "status", "mood", "run", "open", "exit", "quit", "provider", "stream", "thoughts", "agent", "usage", "cache", "models", "model", "mode", "route"},
 	}
 }
 
@@ -36,6 +39,9 @@ type Interface struct {
 	completer       *Completer
 	renderer        *StreamingRenderer
 	thoughtRenderer *ThoughtRenderer
+	input           *bufio.Scanner     // Shared by the prompt loop and confirmations
+	pendingImages   []string           // Images attached with #image: for the next message
+	requestMode     kernel.RequestMode // Set with /mode: race, hedge or compare chat requests across providers
 }
 
 // Represent a CLI command
@@ -56,8 +62,16 @@ type CommandContext struct {
 
 // Create a new CLI interface with advanced streaming capabilities
 func NewInterface(runtime interface{}) *Interface {
+	// Load settings from ~/.nero/config.json (or --config)
+	settings, err := config.Load("")
+	if err != nil {
+		color.New(color.FgRed).Printf("❌ Invalid config:\n%v\n", err)
+		return nil
+	}
+	ApplyTheme(settings.Theme)
+
 	// Initialize the new kernel core
-	core := kernel.NewCore()
+	core := kernel.NewCoreFromConfig(settings)
 	if err := core.Initialize(); err != nil {
 		color.New(color.FgRed).Printf("❌ Failed to initialize AI core: %v\n", err)
 		return nil
@@ -67,7 +81,7 @@ func NewInterface(runtime interface{}) *Interface {
 	availableProviders := core.GetAvailableProviders()
 	if len(availableProviders) == 0 {
 		color.New(color.FgRed).Println("❌ No AI providers available!")
-		color.New(color.FgYellow).Println("💡 Install Ollama or set API keys (OPENAI_API_KEY, GEMINI_API_KEY, GROQ_API_KEY)")
+		color.New(color.FgYellow).Println("💡 Install Ollama or set API keys (OPENAI_API_KEY, ANTHROPIC_API_KEY, GEMINI_API_KEY, GROQ_API_KEY)")
 		return nil
 	}
 
@@ -78,10 +92,14 @@ func NewInterface(runtime interface{}) *Interface {
 		color.New(color.FgGreen).Println("🦙 Using Ollama (local)")
 	case "openai":
 		color.New(color.FgBlue).Println("🌐 Using OpenAI")
+	case "anthropic":
+		color.New(color.FgHiRed).Println("✴️  Using Anthropic Claude")
 	case "gemini":
 		color.New(color.FgMagenta).Println("💎 Using Google Gemini")
 	case "groq":
 		color.New(color.FgYellow).Println("⚡ Using Groq")
+	case "fake":
+		color.New(color.FgHiBlack).Println("🎭 Using scripted fake provider")
 	}
 
 	cli := &Interface{
@@ -92,19 +110,35 @@ func NewInterface(runtime interface{}) *Interface {
 		completer:       NewCompleter(),
 		renderer:        NewStreamingRenderer(),
 		thoughtRenderer: NewThoughtRenderer(),
+		input:           bufio.NewScanner(os.Stdin),
 	}
 
 	// Register default commands
 	cli.registerDefaultCommands()
 
+	// Chat goes through the behavioral engine for personality and kaomoji
+	cli.connectBehavior()
+
 	return cli
 }
 
+// Give the behavioral engine the active provider; its kaomoji calls queue behind the user's requests
+func (cli *Interface) connectBehavior() {
+	active := cli.core.GetActiveProvider()
+	chat, exists := cli.core.ScheduledProvider(active, kernel.PriorityInteractive)
+	if !exists {
+		return
+	}
+	helper, _ := cli.core.ScheduledProvider(active, kernel.PriorityBackground)
+	cli.behavior.SetProviders(chat, helper)
+}
+
 // Start the interactive CLI session with streaming support
 func (cli *Interface) Start(ctx context.Context) {
+	defer cli.core.Close()
 	cli.printWelcome()
 
-	scanner := bufio.NewScanner(os.Stdin)
+	scanner := cli.input
 
 	for {
 		select {
@@ -191,8 +225,14 @@ func (cli *Interface) handleResourceAccess(input string) {
 func (cli *Interface) handleChatStreaming(input string) {
 	ctx := context.Background()
 
+	// Attached images go straight to a vision model
+	if len(cli.pendingImages) > 0 {
+		cli.handleVisionChat(ctx, input)
+		return
+	}
+
 	// Process through behavioral engine first for personality
-	if cli.behavior != nil {
+	if cli.behavior != nil && cli.behavior.Connected() {
 		response, err := cli.behavior.ProcessResponse(ctx, input)
 		if err != nil {
 			cli.printError(fmt.Sprintf("Behavioral processing error: %v", err))
@@ -212,6 +252,7 @@ func (cli *Interface) handleChatStreaming(input string) {
 		EnableThoughts: true,
 		Temperature:    0.8,
 		MaxTokens:      500,
+		Mode:           cli.requestMode,
 	}
 
 	// Process request through kernel core
@@ -224,6 +265,8 @@ func (cli *Interface) handleChatStreaming(input string) {
 	// Handle streaming response
 	if response.StreamID != "" {
 		cli.handleStreamingResponse(ctx, response.StreamID, input)
+	} else if len(response.Answers) > 0 {
+		cli.displayAnswers(response.Answers)
 	} else {
 		// Fallback to non-streaming display
 		cli.displayResponse(&behavioral.Response{
@@ -233,6 +276,45 @@ func (cli *Interface) handleChatStreaming(input string) {
 	}
 }
 
+// Send the message with pending images to a vision-capable provider
+func (cli *Interface) handleVisionChat(ctx context.Context, input string) {
+	images := cli.pendingImages
+	cli.pendingImages = nil
+
+	if strings.TrimSpace(input) == "" {
+		input = "What's in this image?"
+	}
+
+	req := &kernel.AIRequest{
+		Messages: []providers.Message{
+			{Role: "user", Content: input},
+		},
+		EnableStream: true,
+		EnableVision: true,
+		Images:       images,
+		Temperature:  0.7,
+		MaxTokens:    1000,
+	}
+	if cli.behavior != nil {
+		req.SystemPrompt = cli.behavior.GetPersonalityPrompt()
+	}
+
+	response, err := cli.core.ProcessRequest(ctx, req)
+	if err != nil {
+		cli.printError(fmt.Sprintf("AI Error: %v", err))
+		return
+	}
+
+	if response.StreamID != "" {
+		cli.handleStreamingResponse(ctx, response.StreamID, input)
+	} else {
+		cli.displayResponse(&behavioral.Response{
+			Text: response.Content,
+			Tone: "neutral",
+		})
+	}
+}
+
 // Simulate streaming for behavioral responses
 func (cli *Interface) simulateStreaming(text string, tone string) {
 	// Start streaming visualization
@@ -251,13 +333,20 @@ func (cli *Interface) simulateStreaming(text string, tone string) {
 
 // Handle real-time streaming response with thoughts
 func (cli *Interface) handleStreamingResponse(ctx context.Context, streamID string, originalInput string) {
-	// Get the streaming channel
-	streamChan, exists := cli.core.GetStreamChannel(streamID)
+	// Get the streaming channel, released when we return
+	subscription, unsubscribe := context.WithCancel(ctx)
+	defer unsubscribe()
+	streamChan, exists := cli.core.Subscribe(subscription, streamID)
 	if !exists {
 		cli.printError("Stream not found")
 		return
 	}
 
+	// Ctrl+C stops this response instead of the whole program
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
 	// Start streaming visualization
 	cli.renderer.StartStreaming()
 
@@ -274,6 +363,21 @@ func (cli *Interface) handleStreamingResponse(ctx context.Context, streamID stri
 				return
 			}
 
+			if chunk.Switch != nil {
+				if thoughtsStarted {
+					cli.renderer.thoughtRenderer.StopThinking()
+					thoughtsStarted = false
+				}
+				cli.printProviderSwitch(chunk.Switch)
+				continue
+			}
+
+			if errors.Is(chunk.Error, context.Canceled) {
+				cli.renderer.StopStreaming()
+				fmt.Printf("\n💭 Response interrupted. Continue chatting...\n")
+				return
+			}
+
 			if chunk.Error != nil {
 				cli.printError(fmt.Sprintf("Streaming error: %v", chunk.Error))
 				cli.renderer.StopStreaming()
@@ -304,6 +408,10 @@ func (cli *Interface) handleStreamingResponse(ctx context.Context, streamID stri
 				return
 			}
 
+		case <-interrupt:
+			// The stream ends with a cancelled chunk, handled above
+			cli.core.CancelStream(streamID)
+
 		case <-ctx.Done():
 			cli.renderer.StopStreaming()
 			return
@@ -333,6 +441,21 @@ func (cli *Interface) displayResponse(response *behavioral.Response) {
 	fmt.Printf("%s\n\n", colorFunc(response.Text))
 }
 
+// Print each provider's answer to a compare request
+func (cli *Interface) displayAnswers(answers []*kernel.AIResponse) {
+	for _, answer := range answers {
+		name := answer.Provider
+		if answer.Model != "" {
+			name += "/" + answer.Model
+		}
+		color.New(color.FgCyan, color.Bold).Printf("── %s (%s)\n", name, answer.ProcessTime.Round(time.Millisecond))
+		cli.displayResponse(&behavioral.Response{
+			Text: answer.Content,
+			Tone: "neutral",
+		})
+	}
+}
+
 // Print welcome message
 func (cli *Interface) printWelcome() {
 	welcome := `
@@ -367,6 +490,18 @@ func (cli *Interface) printGoodbye() {
 	color.New(color.FgMagenta).Print(goodbye)
 }
 
+// Ask a yes/no question on the terminal; anything but y or yes declines
+func (cli *Interface) confirm(question string) bool {
+	color.New(color.FgYellow).Printf("%s [y/N] ", question)
+	if !cli.input.Scan() {
+		fmt.Println()
+		return false
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(cli.input.Text()))
+	return answer == "y" || answer == "yes"
+}
+
 // Print input prompt
 func (cli *Interface) printPrompt() {
 	var mood string
@@ -385,6 +520,18 @@ func (cli *Interface) printPrompt() {
 	color.New(color.FgHiYellow).Printf("❯ ")
 }
 
+// Show that a fallback provider took over mid-response
+func (cli *Interface) printProviderSwitch(change *kernel.ProviderSwitch) {
+	action := "retrying with"
+	if change.Resumed {
+		action = "continuing on"
+	}
+
+	fmt.Println()
+	color.New(color.FgYellow, color.Faint).Printf("   ↪ %s failed, %s %s\n", change.From, action, change.To)
+	fmt.Print("   ")
+}
+
 // Print error messages
 func (cli *Interface) printError(message string) {
 	color.New(color.FgRed).Printf("❌ %s\n", message)
@@ -420,6 +567,11 @@ func (cli *Interface) removeResourceTags(input string) string {
 
 // Process specific resource access
 func (cli *Interface) processResource(resource string, input string) {
+	if path, ok := strings.CutPrefix(resource, "image:"); ok {
+		cli.handleImageResource(path)
+		return
+	}
+
 	color.New(color.FgCyan).Printf("🔍 Accessing %s resource...\n", resource)
 
 	switch resource {
@@ -434,6 +586,24 @@ func (cli *Interface) processResource(resource string, input string) {
 	}
 }
 
+// Handle image attachment, validating local files before the request is sent
+func (cli *Interface) handleImageResource(path string) {
+	if path == "" {
+		cli.printError("Usage: #image:<path or URL>")
+		return
+	}
+
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		if _, err := providers.LoadImageDataURL(path); err != nil {
+			cli.printError(fmt.Sprintf("Cannot attach image: %v", err))
+			return
+		}
+	}
+
+	cli.pendingImages = append(cli.pendingImages, path)
+	color.New(color.FgGreen).Printf("🖼️  Image attached: %s\n", path)
+}
+
 // Handle terminal resource access
 func (cli *Interface) handleTerminalResource() {
 	// Get current working directory
@@ -7,7 +7,10 @@ import (
 	"strings"
 	"time"
 
+	"nero/config"
+
 	"github.com/charmbracelet/lipgloss"
+	"github.com/fatih/color"
 	"github.com/muesli/termenv"
 )
 
@@ -54,6 +57,18 @@ var (
 			Italic(true)
 )
 
+// Apply colors from the config file's theme
+func ApplyTheme(theme config.ThemeConfig) {
+	if theme.NoColor {
+		color.NoColor = true
+		lipgloss.SetColorProfile(termenv.Ascii)
+		return
+	}
+
+	promptStyle = promptStyle.Foreground(lipgloss.Color(theme.Prompt))
+	neroStyle = neroStyle.Foreground(lipgloss.Color(theme.Accent))
+}
+
 type REPL struct {
 	suggestions   []string
 	history       []string
@@ -242,7 +257,7 @@ func (repl *REPL) getSuggestions(input string) []string {
 	var suggestions []string
 
 	if strings.HasPrefix(input, "/") {
-		commands := []string{"/help", "/clear", "/status", "/quit", "/exit"}
+		commands := []string{"/help", "/clear", "/status", "/usage", "/quit", "/exit"}
 		for _, cmd := range commands {
 			if strings.HasPrefix(cmd, input) {
 				suggestions = append(suggestions, commandStyle.Render(cmd))
@@ -256,7 +271,7 @@ func (repl *REPL) getSuggestions(input string) []string {
 			}
 		}
 	} else if strings.HasPrefix(input, "#") {
-		resources := []string{"#terminal", "#screen", "#code", "#memory", "#config"}
+		resources := []string{"#terminal", "#screen", "#code", "#memory", "#config", "#image:"}
 		for _, res := range resources {
 			if strings.HasPrefix(res, input) {
 				suggestions = append(suggestions, resourceStyle.Render(res))
@@ -0,0 +1,336 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"nero/providers"
+)
+
+// Environment variable overriding the config file location, set by --config
+const PathEnv = "NERO_CONFIG"
+
+// Hold everything Nero reads from ~/.nero/config.json
+type Config struct {
+	Providers []ProviderConfig `json:"providers"` // Registered in order; the first available becomes active without a default
+	Routing   RoutingConfig    `json:"routing"`
+	Behavior  BehaviorConfig   `json:"behavior"`
+	Theme     ThemeConfig      `json:"theme"`
+
+	path string
+}
+
+// Describe one AI provider
+type ProviderConfig struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type,omitempty"` // ollama, openai, groq, gemini, anthropic or compatible; defaults to name
+	Model   string            `json:"model"`
+	BaseURL string            `json:"base_url,omitempty"`
+	Key     string            `json:"key,omitempty"` // env:VAR or store:name; empty uses NAME_API_KEY and the credential store
+	Headers map[string]string `json:"headers,omitempty"`
+	Vision  bool              `json:"vision,omitempty"` // Compatible models that accept images
+
+	Tasks         []string `json:"tasks,omitempty"`          // Tasks routing should prefer this provider for: quick, code, long_context, vision, private or general
+	ContextWindow int      `json:"context_window,omitempty"` // Tokens the model accepts; 0 uses a built-in estimate
+}
+
+// Configure how requests are routed, retried and cached
+type RoutingConfig struct {
+	DefaultProvider    string   `json:"default_provider"`
+	Fallbacks          []string `json:"fallbacks"`
+	Streaming          bool     `json:"streaming"`
+	Vision             bool     `json:"vision"`
+	Reasoning          bool     `json:"reasoning"`
+	MaxConcurrentCalls int      `json:"max_concurrent_calls"`
+	MaxAgentSteps      int      `json:"max_agent_steps"`
+	RequestTimeout     string   `json:"request_timeout"` // Go duration, e.g. "2m"
+	Cache              bool     `json:"cache"`
+	CacheTTL           string   `json:"cache_ttl"`
+	CacheMaxMB         int      `json:"cache_max_mb"`
+	HealthInterval     string   `json:"health_interval"`
+	BreakerThreshold   int      `json:"breaker_threshold"`
+	BreakerCooldown    string   `json:"breaker_cooldown"`
+	HedgeAfter         string   `json:"hedge_after"` // Wait for a first token before hedged requests start a backup provider
+
+	Classifier string        `json:"classifier"`      // "heuristic", or local provider[/model] of a small model that labels each request
+	Rules      []RoutingRule `json:"rules,omitempty"` // Checked in order before the built-in preferences
+}
+
+// Send matching requests to a chosen provider, and optionally model
+type RoutingRule struct {
+	Task     string `json:"task,omitempty"`  // quick, code, long_context, vision, private or general; empty matches any
+	Match    string `json:"match,omitempty"` // Regular expression on the last user message; empty matches any
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"` // Defaults to the provider's current model
+}
+
+// Configure Nero's personality and self-management
+type BehaviorConfig struct {
+	Spin         string            `json:"spin"` // full or lite
+	Personality  string            `json:"personality"`
+	VoiceEnabled bool              `json:"voice_enabled"`
+	AutoSave     bool              `json:"auto_save"`
+	Preferences  map[string]string `json:"preferences"`
+}
+
+// Configure terminal colors
+type ThemeConfig struct {
+	Prompt  string `json:"prompt"` // Hex color of the input prompt
+	Accent  string `json:"accent"` // Hex color of Nero's name and highlights
+	NoColor bool   `json:"no_color"`
+}
+
+// Return the default config file location (~/.nero/config.json)
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".nero", "config.json")
+	}
+	return filepath.Join(home, ".nero", "config.json")
+}
+
+// Return the config file in use: NERO_CONFIG when set, else the default
+func Path() string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+	return DefaultPath()
+}
+
+// Return the built-in settings used when no config file exists
+func Default() *Config {
+	return &Config{
+		Providers: []ProviderConfig{
+			{Name: "ollama", Model: "llama3.2"},
+			{Name: "openai", Model: "gpt-4o"},
+			{Name: "gemini", Model: "gemini-2.0-flash-exp"},
+			{Name: "groq", Model: "llama-3.3-70b-versatile"},
+			{Name: "anthropic", Model: "claude-sonnet-4-5"},
+		},
+		Routing: RoutingConfig{
+			DefaultProvider:    "ollama",
+			Fallbacks:          []string{"openai", "anthropic", "gemini", "groq"},
+			Streaming:          true,
+			Vision:             true,
+			Reasoning:          true,
+			MaxConcurrentCalls: 10,
+			MaxAgentSteps:      8,
+			RequestTimeout:     "2m",
+			CacheTTL:           "24h",
+			CacheMaxMB:         64,
+			HealthInterval:     "30s",
+			BreakerThreshold:   3,
+			BreakerCooldown:    "30s",
+			HedgeAfter:         "2s",
+			Classifier:         "heuristic",
+		},
+		Behavior: BehaviorConfig{
+			Spin:         "full",
+			Personality:  "tsundere",
+			VoiceEnabled: true,
+			AutoSave:     true,
+			Preferences:  make(map[string]string),
+		},
+		Theme: ThemeConfig{
+			Prompt: "#BD93F9",
+			Accent: "#FF79C6",
+		},
+		path: DefaultPath(),
+	}
+}
+
+// Load and validate a config file; "" means Path(), and a missing default file gives the defaults
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = Path()
+	}
+
+	config := Default()
+	config.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && path == DefaultPath() {
+			return config, nil
+		}
+		return nil, err
+	}
+
+	if err := config.decode(data); err != nil {
+		return nil, err
+	}
+	if config.Behavior.Preferences == nil {
+		config.Behavior.Preferences = make(map[string]string)
+	}
+	return config, nil
+}
+
+// Parse data over the defaults, reporting syntax, type and value errors with their line
+func (c *Config) decode(data []byte) error {
+	// A declared provider list replaces the defaults rather than merging into their entries
+	defaults := c.Providers
+	c.Providers = nil
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(c); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return Errors{newError(c.path, data, syntaxErr.Offset, "", syntaxErr.Error())}
+		case errors.As(err, &typeErr):
+			return Errors{newError(c.path, data, typeErr.Offset, typeErr.Field, "expected "+typeErr.Type.String())}
+		default:
+			// Unknown fields carry no offset; find the key by name instead
+			field := strings.TrimSuffix(strings.TrimPrefix(err.Error(), `json: unknown field "`), `"`)
+			for path, offset := range fieldOffsets(data) {
+				if path == field || strings.HasSuffix(path, "."+field) {
+					return Errors{newError(c.path, data, offset, path, "unknown field")}
+				}
+			}
+			return Errors{&Error{File: c.path, Message: err.Error()}}
+		}
+	}
+
+	offsets := fieldOffsets(data)
+	if _, declared := offsets["providers"]; declared {
+		c.deriveRouting(offsets)
+	} else {
+		c.Providers = defaults
+	}
+
+	if errs := c.Validate(); len(errs) > 0 {
+		for _, err := range errs {
+			// Missing fields point at the object that should hold them
+			for field := err.Field; field != ""; field = parentField(field) {
+				if offset, exists := offsets[field]; exists {
+					err.Line, err.Column = position(data, offset)
+					break
+				}
+			}
+			err.File = c.path
+		}
+
+		// Report in file order; settings left at their defaults have no line and go last
+		sort.SliceStable(errs, func(i, j int) bool {
+			return errs[j].Line == 0 && errs[i].Line > 0 || errs[i].Line > 0 && errs[i].Line < errs[j].Line
+		})
+		return errs
+	}
+	return nil
+}
+
+// Point the default provider and fallbacks the file leaves unset at its own providers, not the built-in ones
+func (c *Config) deriveRouting(offsets map[string]int64) {
+	if _, declared := offsets["routing.default_provider"]; !declared {
+		// Without a usable default, the first available provider becomes active
+		if _, exists := c.Provider(c.Routing.DefaultProvider); !exists {
+			c.Routing.DefaultProvider = ""
+		}
+	}
+
+	if _, declared := offsets["routing.fallbacks"]; !declared {
+		c.Routing.Fallbacks = nil
+		for _, provider := range c.Providers {
+			if provider.Name != c.Routing.DefaultProvider {
+				c.Routing.Fallbacks = append(c.Routing.Fallbacks, provider.Name)
+			}
+		}
+	}
+}
+
+// Write the config back to the file it came from
+func (c *Config) Save() error {
+	if errs := c.Validate(); len(errs) > 0 {
+		return errs
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Return the file this config is read from and saved to
+func (c *Config) File() string {
+	return c.path
+}
+
+// Return the settings for a provider by name
+func (c *Config) Provider(name string) (ProviderConfig, bool) {
+	for _, provider := range c.Providers {
+		if provider.Name == name {
+			return provider, true
+		}
+	}
+	return ProviderConfig{}, false
+}
+
+// Return the provider type, which defaults to its name
+func (p ProviderConfig) Kind() string {
+	if p.Type != "" {
+		return p.Type
+	}
+	return p.Name
+}
+
+// Report whether the provider runs on this machine, so requests never leave it
+func (p ProviderConfig) Local() bool {
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		if p.Kind() != "ollama" {
+			return false
+		}
+		// Ollama without a base_url talks to OLLAMA_HOST, which may be another machine
+		baseURL = providers.OllamaHost()
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Split a classifier setting into provider and optional model; heuristic gives ""
+func (r RoutingConfig) ClassifierProvider() (string, string) {
+	if r.Classifier == "" || r.Classifier == "heuristic" {
+		return "", ""
+	}
+	provider, model, _ := strings.Cut(r.Classifier, "/")
+	return provider, model
+}
+
+// Parse a validated duration setting; invalid or empty values give 0
+func Duration(value string) time.Duration {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Load a config file with the given contents
+func load(t *testing.T, contents string) (*Config, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestLoadReplacesDefaultProviders(t *testing.T) {
+	config, err := load(t, `{
+  "providers": [
+    {"name": "lmstudio", "type": "compatible", "base_url": "http://localhost:1234/v1", "model": "qwen2.5"},
+    {"name": "openai", "model": "gpt-4o-mini"}
+  ]
+}`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if len(config.Providers) != 2 || config.Providers[0].Name != "lmstudio" || config.Providers[1].Model != "gpt-4o-mini" {
+		t.Errorf("providers = %+v, want only the declared two", config.Providers)
+	}
+	if config.Routing.DefaultProvider != "" {
+		t.Errorf("default_provider = %q, want none so the first available is used", config.Routing.DefaultProvider)
+	}
+	if want := []string{"lmstudio", "openai"}; !reflect.DeepEqual(config.Routing.Fallbacks, want) {
+		t.Errorf("fallbacks = %v, want %v", config.Routing.Fallbacks, want)
+	}
+}
+
+func TestLoadKeepsDeclaredRouting(t *testing.T) {
+	config, err := load(t, `{
+  "providers": [
+    {"name": "ollama", "model": "qwen2.5-coder"},
+    {"name": "groq", "model": "llama-3.3-70b-versatile"},
+    {"name": "gemini", "model": "gemini-2.0-flash"}
+  ],
+  "routing": {"fallbacks": ["gemini"]}
+}`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if config.Routing.DefaultProvider != "ollama" {
+		t.Errorf("default_provider = %q, want the built-in default while it is declared", config.Routing.DefaultProvider)
+	}
+	if want := []string{"gemini"}; !reflect.DeepEqual(config.Routing.Fallbacks, want) {
+		t.Errorf("fallbacks = %v, want %v", config.Routing.Fallbacks, want)
+	}
+}
+
+func TestLoadReportsMissingModel(t *testing.T) {
+	// The first entry must not inherit the default ollama model
+	_, err := load(t, `{
+  "providers": [
+    {"name": "ollama"}
+  ]
+}`)
+	if err == nil || !strings.Contains(err.Error(), "providers[0].model") || !strings.Contains(err.Error(), ":3:") {
+		t.Errorf("err = %v, want providers[0].model is required on line 3", err)
+	}
+}
+
+func TestLoadWithoutProvidersKeepsDefaults(t *testing.T) {
+	config, err := load(t, `{"behavior": {"spin": "lite"}}`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	defaults := Default()
+	if !reflect.DeepEqual(config.Providers, defaults.Providers) || !reflect.DeepEqual(config.Routing.Fallbacks, defaults.Routing.Fallbacks) {
+		t.Errorf("providers = %+v, fallbacks = %v; want the defaults", config.Providers, config.Routing.Fallbacks)
+	}
+}
+
+func TestProviderLocal(t *testing.T) {
+	tests := []struct {
+		provider   ProviderConfig
+		ollamaHost string
+		want       bool
+	}{
+		{ProviderConfig{Name: "ollama"}, "", true},
+		{ProviderConfig{Name: "ollama"}, "127.0.0.1:11434", true},
+		{ProviderConfig{Name: "ollama"}, "gpu-box.lan", false},
+		{ProviderConfig{Name: "ollama", BaseURL: "http://localhost:11434"}, "gpu-box.lan", true},
+		{ProviderConfig{Name: "lmstudio", Type: "compatible", BaseURL: "http://[::1]:1234/v1"}, "", true},
+		{ProviderConfig{Name: "openai"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("OLLAMA_HOST", tt.ollamaHost)
+		if got := tt.provider.Local(); got != tt.want {
+			t.Errorf("%+v with OLLAMA_HOST=%q: Local() = %v, want %v", tt.provider, tt.ollamaHost, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRejectsCloudClassifier(t *testing.T) {
+	_, err := load(t, `{
+  "providers": [
+    {"name": "ollama", "model": "qwen2.5-coder"},
+    {"name": "groq", "model": "llama-3.1-8b-instant"}
+  ],
+  "routing": {"classifier": "groq"}
+}`)
+	if err == nil || !strings.Contains(err.Error(), "routing.classifier") {
+		t.Errorf("err = %v, want routing.classifier must be local", err)
+	}
+}
@@ -0,0 +1,297 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Provider types Nero knows how to build
+var providerTypes = map[string]bool{
+	"ollama":     true,
+	"openai":     true,
+	"groq":       true,
+	"gemini":     true,
+	"anthropic":  true,
+	"compatible": true,
+}
+
+// Task classes routing rules and provider preferences can name
+var taskClasses = map[string]bool{
+	"quick":        true,
+	"code":         true,
+	"long_context": true,
+	"vision":       true,
+	"private":      true,
+	"general":      true,
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Describe a problem with one config field
+type Error struct {
+	File    string
+	Line    int // 0 when the position is unknown
+	Column  int
+	Field   string // e.g. providers[1].model
+	Message string
+}
+
+func (e *Error) Error() string {
+	var location strings.Builder
+	location.WriteString(e.File)
+	if e.Line > 0 {
+		fmt.Fprintf(&location, ":%d:%d", e.Line, e.Column)
+	}
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s: %s", location.String(), e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", location.String(), e.Message)
+}
+
+// Collect every problem found in a config file
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func newError(file string, data []byte, offset int64, field, message string) *Error {
+	line, column := position(data, offset)
+	return &Error{File: file, Line: line, Column: column, Field: field, Message: message}
+}
+
+// Check values that JSON decoding alone cannot
+func (c *Config) Validate() Errors {
+	var errs Errors
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, &Error{File: c.path, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	names := make(map[string]bool)
+	for i, provider := range c.Providers {
+		field := fmt.Sprintf("providers[%d]", i)
+
+		switch {
+		case provider.Name == "":
+			fail(field+".name", "is required")
+		case names[provider.Name]:
+			fail(field+".name", "duplicate provider %q", provider.Name)
+		}
+		names[provider.Name] = true
+
+		kind := provider.Kind()
+		switch {
+		case !providerTypes[kind] && provider.Type == "":
+			fail(field+".type", "is required for custom provider %q - use compatible for OpenAI-compatible endpoints", provider.Name)
+		case !providerTypes[kind]:
+			fail(field+".type", "unknown provider type %q (ollama, openai, groq, gemini, anthropic or compatible)", provider.Type)
+		case kind != "compatible" && kind != provider.Name:
+			fail(field+".type", "built-in %s provider must be named %q - use compatible for extra endpoints", kind, kind)
+		}
+
+		if provider.Model == "" {
+			fail(field+".model", "is required")
+		}
+
+		if provider.BaseURL != "" {
+			if u, err := url.Parse(provider.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				fail(field+".base_url", "must be an http(s) URL")
+			}
+		} else if kind == "compatible" {
+			fail(field+".base_url", "is required for compatible providers")
+		}
+
+		if provider.Key != "" && !strings.HasPrefix(provider.Key, "env:") && !strings.HasPrefix(provider.Key, "store:") {
+			fail(field+".key", "must be env:VAR or store:name - keep keys out of the config file (nero auth set %s)", provider.Name)
+		}
+
+		for j, task := range provider.Tasks {
+			if !taskClasses[task] {
+				fail(fmt.Sprintf("%s.tasks[%d]", field, j), "unknown task %q (quick, code, long_context, vision, private or general)", task)
+			}
+		}
+		if provider.ContextWindow < 0 {
+			fail(field+".context_window", "cannot be negative")
+		}
+	}
+
+	routing := c.Routing
+	if routing.DefaultProvider != "" && !names[routing.DefaultProvider] {
+		fail("routing.default_provider", "unknown provider %q", routing.DefaultProvider)
+	}
+	for i, name := range routing.Fallbacks {
+		if !names[name] {
+			fail(fmt.Sprintf("routing.fallbacks[%d]", i), "unknown provider %q", name)
+		}
+	}
+	if routing.MaxConcurrentCalls < 1 {
+		fail("routing.max_concurrent_calls", "must be at least 1")
+	}
+	if routing.MaxAgentSteps < 1 {
+		fail("routing.max_agent_steps", "must be at least 1")
+	}
+	if routing.CacheMaxMB < 0 {
+		fail("routing.cache_max_mb", "cannot be negative")
+	}
+	if routing.BreakerThreshold < 1 {
+		fail("routing.breaker_threshold", "must be at least 1")
+	}
+	for _, setting := range []struct{ field, value string }{
+		{"routing.request_timeout", routing.RequestTimeout},
+		{"routing.cache_ttl", routing.CacheTTL},
+		{"routing.health_interval", routing.HealthInterval},
+		{"routing.breaker_cooldown", routing.BreakerCooldown},
+		{"routing.hedge_after", routing.HedgeAfter},
+	} {
+		if duration, err := time.ParseDuration(setting.value); err != nil || duration <= 0 {
+			fail(setting.field, "must be a positive duration like \"30s\" or \"2m\", got %q", setting.value)
+		}
+	}
+
+	if name, _ := routing.ClassifierProvider(); name != "" {
+		if provider, ok := c.Provider(name); !ok {
+			fail("routing.classifier", "must be heuristic or a configured provider, got %q", routing.Classifier)
+		} else if !provider.Local() {
+			fail("routing.classifier", "must be a local provider, since it reads every request, got %q", routing.Classifier)
+		}
+	}
+	for i, rule := range routing.Rules {
+		field := fmt.Sprintf("routing.rules[%d]", i)
+		if rule.Task == "" && rule.Match == "" {
+			fail(field, "needs a task or a match")
+		}
+		if rule.Task != "" && !taskClasses[rule.Task] {
+			fail(field+".task", "unknown task %q (quick, code, long_context, vision, private or general)", rule.Task)
+		}
+		if _, err := regexp.Compile(rule.Match); err != nil {
+			fail(field+".match", "invalid regular expression: %v", err)
+		}
+		if !names[rule.Provider] {
+			fail(field+".provider", "unknown provider %q", rule.Provider)
+		}
+	}
+
+	if c.Behavior.Spin != "full" && c.Behavior.Spin != "lite" {
+		fail("behavior.spin", "must be full or lite, got %q", c.Behavior.Spin)
+	}
+	if c.Behavior.Personality == "" {
+		fail("behavior.personality", "is required")
+	}
+
+	if !hexColor.MatchString(c.Theme.Prompt) {
+		fail("theme.prompt", "must be a hex color like #BD93F9, got %q", c.Theme.Prompt)
+	}
+	if !hexColor.MatchString(c.Theme.Accent) {
+		fail("theme.accent", "must be a hex color like #FF79C6, got %q", c.Theme.Accent)
+	}
+
+	return errs
+}
+
+type pathFrame struct {
+	array bool
+	index int
+	key   string // Key of the value being read in an object, "" while expecting a key
+	path  string
+}
+
+// Path of the value currently being read in this container
+func (f *pathFrame) child() string {
+	if f.array {
+		return fmt.Sprintf("%s[%d]", f.path, f.index)
+	}
+	if f.path == "" {
+		return f.key
+	}
+	return f.path + "." + f.key
+}
+
+// Move past a finished value
+func (f *pathFrame) advance() {
+	if f.array {
+		f.index++
+	} else {
+		f.key = ""
+	}
+}
+
+// Map each field path, like providers[1].model, to the offset of its key in the file
+func fieldOffsets(data []byte) map[string]int64 {
+	offsets := make(map[string]int64)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var stack []*pathFrame
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return offsets
+		}
+		offset := decoder.InputOffset()
+
+		var top *pathFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		// Inside an object, strings alternate between keys and values
+		if top != nil && !top.array && top.key == "" {
+			if key, ok := token.(string); ok {
+				top.key = key
+				offsets[top.child()] = offset - int64(len(key)+2) // Point at the opening quote
+				continue
+			}
+		}
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				stack[len(stack)-1].advance()
+			}
+			continue
+		}
+
+		path := ""
+		if top != nil {
+			path = top.child()
+			if top.array {
+				offsets[path] = offset
+			}
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			stack = append(stack, &pathFrame{array: delim == '[', path: path})
+			continue
+		}
+		if top != nil {
+			top.advance()
+		}
+	}
+}
+
+// Drop the last segment of a field path: providers[1].model -> providers[1] -> providers
+func parentField(field string) string {
+	if i := strings.LastIndexAny(field, ".["); i > 0 {
+		return field[:i]
+	}
+	return ""
+}
+
+// Convert a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
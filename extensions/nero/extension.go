@@ -3,6 +3,8 @@ package extensions
 import (
 	"encoding/json"
 	"fmt"
+
+	"nero/config"
 )
 
 type NeroManifest struct {
@@ -35,28 +37,16 @@ const (
 	SpinLite ConfigSpin = "lite"
 )
 
-type NeroConfig struct {
-	Spin         ConfigSpin        `json:"spin"`
-	Personality  string            `json:"personality"`
-	VoiceEnabled bool              `json:"voice_enabled"`
-	AutoSave     bool              `json:"auto_save"`
-	Preferences  map[string]string `json:"preferences"`
-}
-
 type NeroExtension struct {
-	config   *NeroConfig
+	settings *config.Config
+	config   *config.BehaviorConfig // The behavior section of settings
 	commands map[string]func([]string) (string, error)
 }
 
-func NewNeroExtension() *NeroExtension {
+func NewNeroExtension(settings *config.Config) *NeroExtension {
 	return &NeroExtension{
-		config: &NeroConfig{
-			Spin:         SpinFull,
-			Personality:  "tsundere",
-			VoiceEnabled: true,
-			AutoSave:     true,
-			Preferences:  make(map[string]string),
-		},
+		settings: settings,
+		config:   &settings.Behavior,
 		commands: make(map[string]func([]string) (string, error)),
 	}
 }
@@ -116,10 +106,18 @@ func (ne *NeroExtension) ExecuteCommand(command string, args []string) (string,
 	return "", fmt.Errorf("command not found: %s", command)
 }
 
-func (ne *NeroExtension) GetConfig() *NeroConfig {
+func (ne *NeroExtension) GetConfig() *config.BehaviorConfig {
 	return ne.config
 }
 
+// Persist a change to the config file, reporting where it went
+func (ne *NeroExtension) save(message string) (string, error) {
+	if err := ne.settings.Save(); err != nil {
+		return "", fmt.Errorf("%s, but saving %s failed: %w", message, ne.settings.File(), err)
+	}
+	return message, nil
+}
+
 func (ne *NeroExtension) handleConfig(args []string) (string, error) {
 	if len(args) == 0 {
 		// Show current config
@@ -135,7 +133,7 @@ func (ne *NeroExtension) handleConfig(args []string) (string, error) {
 		key := args[0]
 		switch key {
 		case "spin":
-			return string(ne.config.Spin), nil
+			return ne.config.Spin, nil
 		case "personality":
 			return ne.config.Personality, nil
 		case "voice_enabled":
@@ -155,29 +153,29 @@ func (ne *NeroExtension) handleConfig(args []string) (string, error) {
 		key, value := args[0], args[1]
 		switch key {
 		case "spin":
-			if value == "full" || value == "lite" {
-				ne.config.Spin = ConfigSpin(value)
-				return fmt.Sprintf("Spin mode set to: %s", value), nil
+			if value == string(SpinFull) || value == string(SpinLite) {
+				ne.config.Spin = value
+				return ne.save(fmt.Sprintf("Spin mode set to: %s", value))
 			}
 			return "", fmt.Errorf("invalid spin mode: %s (use full or lite)", value)
 		case "personality":
 			ne.config.Personality = value
-			return fmt.Sprintf("Personality set to: %s", value), nil
+			return ne.save(fmt.Sprintf("Personality set to: %s", value))
 		case "voice_enabled":
 			if value == "true" || value == "false" {
 				ne.config.VoiceEnabled = value == "true"
-				return fmt.Sprintf("Voice enabled: %s", value), nil
+				return ne.save(fmt.Sprintf("Voice enabled: %s", value))
 			}
 			return "", fmt.Errorf("invalid boolean value: %s", value)
 		case "auto_save":
 			if value == "true" || value == "false" {
 				ne.config.AutoSave = value == "true"
-				return fmt.Sprintf("Auto save: %s", value), nil
+				return ne.save(fmt.Sprintf("Auto save: %s", value))
 			}
 			return "", fmt.Errorf("invalid boolean value: %s", value)
 		default:
 			ne.config.Preferences[key] = value
-			return fmt.Sprintf("Preference %s set to: %s", key, value), nil
+			return ne.save(fmt.Sprintf("Preference %s set to: %s", key, value))
 		}
 	}
 
@@ -186,7 +184,7 @@ func (ne *NeroExtension) handleConfig(args []string) (string, error) {
 
 func (ne *NeroExtension) handleSpin(args []string) (string, error) {
 	if len(args) == 0 {
-		return string(ne.config.Spin), nil
+		return ne.config.Spin, nil
 	}
 
 	spin := args[0]
@@ -194,13 +192,13 @@ func (ne *NeroExtension) handleSpin(args []string) (string, error) {
 		return "", fmt.Errorf("invalid spin mode: %s (use full or lite)", spin)
 	}
 
-	ne.config.Spin = ConfigSpin(spin)
+	ne.config.Spin = spin
 
 	switch spin {
 	case "full":
-		return "🚀 Full mode activated - all capabilities enabled", nil
+		return ne.save("🚀 Full mode activated - all capabilities enabled")
 	case "lite":
-		return "⚡ Lite mode activated - minimal resource usage", nil
+		return ne.save("⚡ Lite mode activated - minimal resource usage")
 	}
 
 	return "", nil
@@ -209,15 +207,10 @@ func (ne *NeroExtension) handleSpin(args []string) (string, error) {
 func (ne *NeroExtension) handleReset(args []string) (string, error) {
 	// Reset to defaults but keep user preferences
 	preferences := ne.config.Preferences
-	ne.config = &NeroConfig{
-		Spin:         SpinFull,
-		Personality:  "tsundere",
-		VoiceEnabled: true,
-		AutoSave:     true,
-		Preferences:  preferences,
-	}
+	*ne.config = config.Default().Behavior
+	ne.config.Preferences = preferences
 
-	return "🔄 Behavioral state reset to defaults", nil
+	return ne.save("🔄 Behavioral state reset to defaults")
 }
 
 func (ne *NeroExtension) handleStatus(args []string) (string, error) {
@@ -8,6 +8,11 @@ require github.com/muesli/termenv v0.16.0
 
 require github.com/charmbracelet/lipgloss v1.1.0
 
+require (
+	golang.org/x/crypto v0.41.0
+	golang.org/x/term v0.34.0
+)
+
 require (
 	github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
 	github.com/charmbracelet/colorprofile v0.2.3-0.20250311203215-f60798e515dc // indirect
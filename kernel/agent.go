@@ -0,0 +1,323 @@
+package kernel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"nero/providers"
+)
+
+// Execute a tool call and return its textual result
+type ToolHandler func(ctx context.Context, args map[string]interface{}) (string, error)
+
+// Bind a tool definition to the handler that executes it
+type AgentTool struct {
+	Definition providers.Tool
+	Handler    ToolHandler
+	Confirm    bool // Changes the system; runs only when the agent's confirm callback approves the call
+}
+
+// Ask the user whether a tool call may run
+type ToolConfirm func(call providers.ToolCall) bool
+
+// Record a single tool execution during an agent run
+type AgentStep struct {
+	Step     int
+	Call     providers.ToolCall
+	Result   string
+	Error    error
+	Duration time.Duration
+}
+
+// Receive agent steps as they complete
+type AgentTrace func(step AgentStep)
+
+// Drive the call → execute → feed back loop until the model answers
+type Agent struct {
+	core        *Core
+	tools       map[string]AgentTool
+	definitions []providers.Tool
+	maxSteps    int
+	confirm     ToolConfirm
+}
+
+// Limit tool output fed back to the model
+const maxToolResultBytes = 16 * 1024
+
+// Create an agent bound to the core and a toolset
+func NewAgent(core *Core, tools []AgentTool) *Agent {
+	agent := &Agent{
+		core:     core,
+		tools:    make(map[string]AgentTool),
+		maxSteps: core.config.MaxAgentSteps,
+	}
+
+	for _, tool := range tools {
+		agent.tools[tool.Definition.Name] = tool
+		agent.definitions = append(agent.definitions, tool.Definition)
+	}
+
+	return agent
+}
+
+// Set the callback that approves tools marked Confirm; without one those tools are refused
+func (a *Agent) SetConfirm(confirm ToolConfirm) {
+	a.confirm = confirm
+}
+
+// Run the request, executing tool calls until a final answer is produced
+func (a *Agent) Run(ctx context.Context, req *AIRequest, trace AgentTrace) (*AIResponse, error) {
+	// Work on a copy so the caller's request stays untouched
+	turn := *req
+	turn.EnableStream = false
+	turn.Tools = a.definitions
+	turn.Messages = append([]providers.Message(nil), req.Messages...)
+
+	// Route once with the tools attached, then keep every step on the same provider and model
+	provider, decision, err := a.core.selectProvider(ctx, &turn)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("no suitable provider available")
+	}
+	if toolProvider, ok := provider.(providers.ToolProvider); !ok || !toolProvider.SupportsTools() {
+		return nil, fmt.Errorf("provider %s does not support tool calling", provider.Name())
+	}
+	turn.Provider = provider.Name()
+	if decision != nil {
+		turn.Model = decision.Model
+		turn.routedTask = decision.Task
+	}
+
+	steps := 0
+	for {
+		// Out of steps: force the model to answer with what it has
+		if steps >= a.maxSteps {
+			turn.ToolChoice = "none"
+		}
+
+		response, err := a.core.ProcessRequest(ctx, &turn)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(response.ToolCalls) == 0 || steps >= a.maxSteps {
+			if response.Metadata == nil {
+				response.Metadata = make(map[string]interface{})
+			}
+			response.Metadata["agent_steps"] = steps
+			return response, nil
+		}
+
+		turn.Messages = append(turn.Messages, providers.Message{
+			Role:      "assistant",
+			Content:   response.Content,
+			ToolCalls: response.ToolCalls,
+		})
+
+		for _, call := range response.ToolCalls {
+			steps++
+			step := a.execute(ctx, steps, call)
+			if trace != nil {
+				trace(step)
+			}
+
+			result := step.Result
+			if step.Error != nil {
+				result = "error: " + step.Error.Error()
+			}
+			turn.Messages = append(turn.Messages, providers.ToolResultMessage(call, result))
+		}
+	}
+}
+
+// Execute one tool call, capturing timing and errors
+func (a *Agent) execute(ctx context.Context, index int, call providers.ToolCall) AgentStep {
+	step := AgentStep{Step: index, Call: call}
+	start := time.Now()
+
+	tool, exists := a.tools[call.Name]
+	if !exists {
+		step.Error = fmt.Errorf("unknown tool: %s", call.Name)
+		step.Duration = time.Since(start)
+		return step
+	}
+
+	// The model chooses the arguments, so commands and overwrites need the user's approval
+	if tool.Confirm {
+		if a.confirm == nil {
+			step.Error = fmt.Errorf("%s needs confirmation, which is not available here", call.Name)
+		} else if !a.confirm(call) {
+			step.Error = fmt.Errorf("the user declined %s", call.Name)
+		}
+		if step.Error != nil {
+			step.Duration = time.Since(start)
+			return step
+		}
+	}
+
+	result, err := tool.Handler(ctx, call.Arguments)
+	if len(result) > maxToolResultBytes {
+		result = result[:maxToolResultBytes] + "\n... (truncated)"
+	}
+
+	step.Result = result
+	step.Error = err
+	step.Duration = time.Since(start)
+	return step
+}
+
+// Expose SystemProvider capabilities as agent tools
+func SystemTools(system *providers.SystemProvider) []AgentTool {
+	return []AgentTool{
+		{
+			Definition: providers.Tool{
+				Name:        "read_file",
+				Description: "Read a text file and return its contents",
+				Parameters:  objectSchema(map[string]string{"path": "Path of the file to read"}, "path"),
+			},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				path, err := stringArg(args, "path")
+				if err != nil {
+					return "", err
+				}
+				return system.ReadFile(path)
+			},
+		},
+		{
+			Definition: providers.Tool{
+				Name:        "write_file",
+				Description: "Write content to a file, replacing it if it exists",
+				Parameters: objectSchema(map[string]string{
+					"path":    "Path of the file to write",
+					"content": "Full content to write",
+				}, "path", "content"),
+			},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				path, err := stringArg(args, "path")
+				if err != nil {
+					return "", err
+				}
+				content, err := stringArg(args, "content")
+				if err != nil {
+					return "", err
+				}
+				if err := system.WriteFile(path, content); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("wrote %d bytes to %s", len(content), path), nil
+			},
+			Confirm: true,
+		},
+		{
+			Definition: providers.Tool{
+				Name:        "list_directory",
+				Description: "List the entries of a directory",
+				Parameters:  objectSchema(map[string]string{"path": "Directory to list"}, "path"),
+			},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				path, err := stringArg(args, "path")
+				if err != nil {
+					return "", err
+				}
+				entries, err := system.ListDirectory(path)
+				if err != nil {
+					return "", err
+				}
+				return strings.Join(entries, "\n"), nil
+			},
+		},
+		{
+			Definition: providers.Tool{
+				Name:        "run_command",
+				Description: "Run a program with arguments and return its combined output",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"command": map[string]interface{}{"type": "string", "description": "Program to execute"},
+						"args": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "Arguments passed to the program",
+						},
+					},
+					"required": []string{"command"},
+				},
+			},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				command, err := stringArg(args, "command")
+				if err != nil {
+					return "", err
+				}
+				var cmdArgs []string
+				if raw, ok := args["args"].([]interface{}); ok {
+					for _, arg := range raw {
+						cmdArgs = append(cmdArgs, fmt.Sprint(arg))
+					}
+				}
+				return system.RunCommand(command, cmdArgs...)
+			},
+			Confirm: true,
+		},
+		{
+			Definition: providers.Tool{
+				Name:        "open_app",
+				Description: "Open an application by name",
+				Parameters:  objectSchema(map[string]string{"name": "Application to open"}, "name"),
+			},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				name, err := stringArg(args, "name")
+				if err != nil {
+					return "", err
+				}
+				if err := system.OpenApp(name); err != nil {
+					return "", err
+				}
+				return "opened " + name, nil
+			},
+			Confirm: true,
+		},
+		{
+			Definition: providers.Tool{
+				Name:        "get_working_directory",
+				Description: "Return the current working directory",
+				Parameters:  objectSchema(map[string]string{}),
+			},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				return system.GetWorkingDirectory()
+			},
+		},
+	}
+}
+
+// Build a JSON Schema object of string properties
+func objectSchema(properties map[string]string, required ...string) map[string]interface{} {
+	props := make(map[string]interface{})
+	for name, description := range properties {
+		props[name] = map[string]interface{}{
+			"type":        "string",
+			"description": description,
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// Extract a required string argument from a tool call
+func stringArg(args map[string]interface{}, key string) (string, error) {
+	value, ok := args[key].(string)
+	if !ok {
+		return "", fmt.Errorf("missing string argument: %s", key)
+	}
+	return value, nil
+}
@@ -0,0 +1,64 @@
+package kernel
+
+import (
+	"context"
+	"testing"
+
+	"nero/providers"
+)
+
+func TestAgentConfirmsSystemChanges(t *testing.T) {
+	ran := 0
+	tools := []AgentTool{
+		{
+			Definition: providers.Tool{Name: "run_command"},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				ran++
+				return "done", nil
+			},
+			Confirm: true,
+		},
+		{
+			Definition: providers.Tool{Name: "get_working_directory"},
+			Handler: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				return "/tmp", nil
+			},
+		},
+	}
+	agent := &Agent{tools: make(map[string]AgentTool)}
+	for _, tool := range tools {
+		agent.tools[tool.Definition.Name] = tool
+	}
+	run := providers.ToolCall{Name: "run_command", Arguments: map[string]interface{}{"command": "rm"}}
+
+	// Without a callback, tools that change the system are refused; others still run
+	if step := agent.execute(context.Background(), 1, run); step.Error == nil {
+		t.Error("run_command ran without a confirm callback")
+	}
+	if step := agent.execute(context.Background(), 2, providers.ToolCall{Name: "get_working_directory"}); step.Error != nil || step.Result != "/tmp" {
+		t.Errorf("get_working_directory = %q, %v", step.Result, step.Error)
+	}
+
+	approve := false
+	agent.SetConfirm(func(call providers.ToolCall) bool { return approve })
+	if step := agent.execute(context.Background(), 3, run); step.Error == nil {
+		t.Error("run_command ran after the user declined")
+	}
+
+	approve = true
+	if step := agent.execute(context.Background(), 4, run); step.Error != nil || step.Result != "done" {
+		t.Errorf("approved run_command = %q, %v", step.Result, step.Error)
+	}
+	if ran != 1 {
+		t.Errorf("handler ran %d times, want 1", ran)
+	}
+}
+
+func TestSystemToolsRequireConfirmation(t *testing.T) {
+	want := map[string]bool{"write_file": true, "run_command": true, "open_app": true}
+	for _, tool := range SystemTools(providers.NewSystemProvider()) {
+		if tool.Confirm != want[tool.Definition.Name] {
+			t.Errorf("%s: Confirm = %v, want %v", tool.Definition.Name, tool.Confirm, want[tool.Definition.Name])
+		}
+	}
+}
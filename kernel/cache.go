@@ -0,0 +1,233 @@
+package kernel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nero/providers"
+)
+
+// Store a finished answer so an identical request can skip the provider
+type CachedResponse struct {
+	Content   string               `json:"content"`
+	Thoughts  string               `json:"thoughts,omitempty"`
+	ToolCalls []providers.ToolCall `json:"tool_calls,omitempty"`
+	Model     string               `json:"model,omitempty"`
+	Created   time.Time            `json:"created"`
+}
+
+// Cache responses in memory, backed by files under a directory with TTL and size limits
+type ResponseCache struct {
+	memory   *Cache
+	dir      string
+	ttl      time.Duration
+	maxBytes int64
+	mu       sync.Mutex
+}
+
+// Return the default on-disk cache location (~/.nero/cache)
+func DefaultCacheDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".nero", "cache")
+	}
+	return filepath.Join(home, ".nero", "cache")
+}
+
+// Create a response cache; an empty dir keeps it in memory only
+func NewResponseCache(dir string, ttl time.Duration, maxBytes int64) *ResponseCache {
+	return &ResponseCache{
+		memory:   NewCache(),
+		dir:      dir,
+		ttl:      ttl,
+		maxBytes: maxBytes,
+	}
+}
+
+// Hash the parts of a request that affect the answer; streaming does not
+func CacheKey(provider, model string, req *AIRequest) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"provider":    provider,
+		"model":       model,
+		"messages":    req.Messages,
+		"system":      req.SystemPrompt,
+		"temperature": req.Temperature,
+		"max_tokens":  req.MaxTokens,
+		"thoughts":    req.EnableThoughts,
+		"tools":       req.Tools,
+		"tool_choice": req.ToolChoice,
+	})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Look a response up in memory, then on disk
+func (rc *ResponseCache) Get(key string) (*CachedResponse, bool) {
+	if value, exists := rc.memory.Get(key); exists {
+		return value.(*CachedResponse), true
+	}
+	if rc.dir == "" {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(rc.file(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var cached CachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		os.Remove(rc.file(key))
+		return nil, false
+	}
+
+	remaining := rc.ttl - time.Since(cached.Created)
+	if remaining <= 0 {
+		os.Remove(rc.file(key))
+		return nil, false
+	}
+
+	rc.memory.Set(key, &cached, remaining)
+	return &cached, true
+}
+
+// Store a response in both tiers; disk failures only cost a future miss
+func (rc *ResponseCache) Set(key string, cached *CachedResponse) {
+	if cached.Created.IsZero() {
+		cached.Created = time.Now()
+	}
+	rc.memory.Set(key, cached, rc.ttl)
+
+	if rc.dir == "" {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if err := os.MkdirAll(rc.dir, 0700); err != nil {
+		return
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(rc.file(key), data, 0600); err != nil {
+		return
+	}
+
+	rc.prune()
+}
+
+// Remove every cached response
+func (rc *ResponseCache) Clear() error {
+	rc.memory.mu.Lock()
+	rc.memory.data = make(map[string]*CacheEntry)
+	rc.memory.mu.Unlock()
+
+	if rc.dir == "" {
+		return nil
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entries, err := os.ReadDir(rc.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".json") {
+			os.Remove(filepath.Join(rc.dir, entry.Name()))
+		}
+	}
+	return nil
+}
+
+// Report how many responses are on disk and how much space they take
+func (rc *ResponseCache) Stats() (entries int, size int64) {
+	if rc.dir == "" {
+		return 0, 0
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for _, file := range rc.files() {
+		entries++
+		size += file.size
+	}
+	return entries, size
+}
+
+func (rc *ResponseCache) file(key string) string {
+	return filepath.Join(rc.dir, key+".json")
+}
+
+type cacheFile struct {
+	path     string
+	size     int64
+	modified time.Time
+}
+
+func (rc *ResponseCache) files() []cacheFile {
+	entries, err := os.ReadDir(rc.dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []cacheFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{
+			path:     filepath.Join(rc.dir, entry.Name()),
+			size:     info.Size(),
+			modified: info.ModTime(),
+		})
+	}
+	return files
+}
+
+// Drop expired files, then the oldest ones until the directory fits in maxBytes
+func (rc *ResponseCache) prune() {
+	files := rc.files()
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modified.Before(files[j].modified)
+	})
+
+	var total int64
+	kept := files[:0]
+	for _, file := range files {
+		if time.Since(file.modified) > rc.ttl {
+			os.Remove(file.path)
+			continue
+		}
+		total += file.size
+		kept = append(kept, file)
+	}
+
+	for _, file := range kept {
+		if rc.maxBytes <= 0 || total <= rc.maxBytes {
+			break
+		}
+		os.Remove(file.path)
+		total -= file.size
+	}
+}
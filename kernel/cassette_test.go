@@ -0,0 +1,84 @@
+package kernel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"nero/config"
+	"nero/providers"
+)
+
+// Build a core whose only configured provider is an OpenAI-compatible server at baseURL, if any
+func cassetteCore(t *testing.T, baseURL string) *Core {
+	t.Helper()
+
+	settings := config.Default()
+	settings.Providers = nil
+	settings.Routing.DefaultProvider = ""
+	settings.Routing.Fallbacks = nil
+	if baseURL != "" {
+		settings.Providers = []config.ProviderConfig{
+			{Name: "local", Type: "compatible", BaseURL: baseURL, Model: "llava", Vision: true},
+		}
+	}
+
+	core := NewCoreFromConfig(settings)
+	if err := core.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	t.Cleanup(core.Close)
+	return core
+}
+
+func TestCoreCassetteReplay(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("NERO_CASSETTE", filepath.Join(t.TempDir(), "cassette.json"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Recorded.\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"Recorded."}}]}`)
+	}))
+	defer server.Close()
+
+	request := func() *AIRequest {
+		return &AIRequest{Messages: []providers.Message{{Role: "user", Content: "hello"}}}
+	}
+
+	// Record against the live server; the wrapped provider keeps its capabilities
+	t.Setenv("NERO_CASSETTE_MODE", "record")
+	recording := cassetteCore(t, server.URL)
+	if !supportsVision(recording.providers["local"]) {
+		t.Error("recorded provider lost vision support")
+	}
+	if _, ok := recording.providers["local"].(providers.ModelSwitcher); !ok {
+		t.Error("recorded provider cannot switch models")
+	}
+	if _, err := recording.ProcessRequest(context.Background(), request()); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	server.Close()
+
+	// Replay with no provider configured and the server gone
+	t.Setenv("NERO_CASSETTE_MODE", "replay")
+	replaying := cassetteCore(t, "")
+	response, err := replaying.ProcessRequest(context.Background(), request())
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if response.Content != "Recorded." || response.Provider != "local" {
+		t.Errorf("replay = %q from %q, want Recorded. from local", response.Content, response.Provider)
+	}
+}
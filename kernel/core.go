@@ -3,20 +3,32 @@ package kernel
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"nero/config"
 	"nero/providers"
+	"nero/providers/credentials"
 )
 
 // Orchestrate all AI operations and provider management
 type Core struct {
 	providers     map[string]providers.AIProvider
+	embedder      providers.EmbeddingProvider
 	activeModel   string
 	memory        *Memory
 	config        *CoreConfig
 	streamManager *StreamManager
+	ledger        *Ledger
+	cache         *ResponseCache
+	health        *HealthMonitor
+	scheduler     *Scheduler
+	settings      *config.Config
+	router        RoutingPolicy
+	stopHealth    context.CancelFunc
 	mu            sync.RWMutex
 }
 
@@ -27,23 +39,16 @@ type CoreConfig struct {
 	StreamingEnabled   bool
 	VisionEnabled      bool
 	ReasoningEnabled   bool
-	MaxConcurrentCalls int
+	MaxConcurrentCalls int // Per provider; further requests queue by priority
+	MaxAgentSteps      int
 	RequestTimeout     time.Duration
-}
-
-// Handle real-time response streaming
-type StreamManager struct {
-	activeStreams map[string]*StreamContext
-	mu            sync.RWMutex
-}
-
-// Track individual streaming context
-type StreamContext struct {
-	ID        string
-	Provider  string
-	StartTime time.Time
-	Channel   chan StreamChunk
-	Cancel    context.CancelFunc
+	CacheEnabled       bool          // Cache every request, not just those that opt in
+	CacheTTL           time.Duration // How long cached responses stay valid
+	CacheMaxBytes      int64         // Size limit for the on-disk cache tier
+	HealthInterval     time.Duration // How often providers are probed for availability
+	BreakerThreshold   int           // Consecutive failures that take a provider out of rotation
+	BreakerCooldown    time.Duration // How long a tripped provider is skipped before it is tried again
+	HedgeAfter         time.Duration // How long a hedged request waits for a first token before starting a backup
 }
 
 // Represent a chunk of streamed content
@@ -51,93 +56,166 @@ type StreamChunk struct {
 	Content   string
 	Type      string // "text", "reasoning", "vision"
 	IsThought bool
+	ToolCalls []providers.ToolCall
 	Delta     bool
 	Done      bool
+	Usage     *providers.Usage // Token counts, on the final chunk when the provider reports them
+	Switch    *ProviderSwitch  // Set on "switch" chunks when a fallback provider takes over
 	Error     error
 }
 
+// Describe a move to a fallback provider in the middle of a stream
+type ProviderSwitch struct {
+	From    string
+	To      string
+	Reason  string // Why the previous provider failed
+	Resumed bool   // The new provider continues the partial answer instead of starting over
+}
+
 // Request for AI processing with full capabilities
 type AIRequest struct {
 	Messages       []providers.Message
-	Provider       string // Optional: specify provider
+	Provider       string    // Optional: specify provider
+	Model          string    // Optional: model on that provider for this request only
+	Task           TaskClass // Optional: skip classification when routing
 	EnableStream   bool
 	EnableVision   bool
 	EnableThoughts bool
 	Temperature    float64
 	MaxTokens      int
 	SystemPrompt   string
+	Tools          []providers.Tool
+	ToolChoice     string
+	Images         []string      // Local paths, http(s) URLs or data URLs attached to the last user message
+	Cache          bool          // Answer from the response cache when an identical request was seen
+	Priority       Priority      // Queue position when the provider is busy; interactive by default
+	Deadline       time.Time     // Give up if still queued at this time; zero waits as long as ctx allows
+	Mode           RequestMode   // Spread the request over several providers: race, hedge or compare
+	Providers      []string      // Providers for race, hedge and compare, in order; defaults to the selected provider and its fallbacks
+	Fanout         int           // Race and compare: how many providers to ask, 2 by default
+	HedgeAfter     time.Duration // Hedge: how long to wait for a first token before starting the backup; 0 uses the configured delay
 	Context        map[string]interface{}
+
+	routedTask TaskClass // Task the routing policy settled on, so fallbacks honour it too
 }
 
 // Response with enhanced capabilities
 type AIResponse struct {
 	Content     string
+	ToolCalls   []providers.ToolCall
 	Provider    string
 	Model       string
 	TokensUsed  int
+	Usage       *providers.Usage
 	ProcessTime time.Duration
 	HasVision   bool
 	HasThoughts bool
 	StreamID    string
+	Answers     []*AIResponse // Compare mode: every provider's answer, in the order asked
 	Metadata    map[string]interface{}
 }
 
-// Initialize the AI processing core
+// Initialize the AI processing core with the built-in settings
 func NewCore() *Core {
-	config := &CoreConfig{
-		DefaultProvider:    "ollama",
-		FallbackProviders:  []string{"openai", "gemini", "groq"},
-		StreamingEnabled:   true,
-		VisionEnabled:      true,
-		ReasoningEnabled:   true,
-		MaxConcurrentCalls: 10,
-		RequestTimeout:     time.Minute * 2,
+	return NewCoreFromConfig(config.Default())
+}
+
+// Initialize the AI processing core from a loaded config file
+func NewCoreFromConfig(settings *config.Config) *Core {
+	routing := settings.Routing
+	coreConfig := &CoreConfig{
+		DefaultProvider:    routing.DefaultProvider,
+		FallbackProviders:  append([]string(nil), routing.Fallbacks...),
+		StreamingEnabled:   routing.Streaming,
+		VisionEnabled:      routing.Vision,
+		ReasoningEnabled:   routing.Reasoning,
+		MaxConcurrentCalls: routing.MaxConcurrentCalls,
+		MaxAgentSteps:      routing.MaxAgentSteps,
+		RequestTimeout:     config.Duration(routing.RequestTimeout),
+		CacheEnabled:       routing.Cache,
+		CacheTTL:           config.Duration(routing.CacheTTL),
+		CacheMaxBytes:      int64(routing.CacheMaxMB) << 20,
+		HealthInterval:     config.Duration(routing.HealthInterval),
+		BreakerThreshold:   routing.BreakerThreshold,
+		BreakerCooldown:    config.Duration(routing.BreakerCooldown),
+		HedgeAfter:         config.Duration(routing.HedgeAfter),
 	}
 
-	return &Core{
+	core := &Core{
 		providers:     make(map[string]providers.AIProvider),
 		memory:        NewMemory(),
-		config:        config,
+		config:        coreConfig,
 		streamManager: NewStreamManager(),
+		ledger:        NewLedger(DefaultLedgerPath()),
+		cache:         NewResponseCache(DefaultCacheDir(), coreConfig.CacheTTL, coreConfig.CacheMaxBytes),
+		health:        NewHealthMonitor(coreConfig.BreakerThreshold, coreConfig.BreakerCooldown),
+		scheduler:     NewScheduler(coreConfig.MaxConcurrentCalls),
+		settings:      settings,
 	}
+
+	core.router = newRoutingPolicy(core, routing)
+	return core
 }
 
 // Initialize all available AI providers
 func (c *Core) Initialize() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.startHealth()
 
-	// Register Ollama provider
-	ollama := providers.NewOllamaProvider("llama3.2")
-	if ollama.IsAvailable() {
-		c.providers["ollama"] = ollama
-		c.activeModel = "ollama"
+	// A scripted fake provider stands in for all real ones, so tests and demos need no network
+	if path := os.Getenv(providers.FakeScriptEnv); path != "" {
+		fake, err := providers.NewFakeProviderFromFile(path)
+		if err != nil {
+			return err
+		}
+		c.config.DefaultProvider = "fake"
+		c.config.FallbackProviders = nil
+		c.activeModel = "fake"
+		c.registerProvider("fake", fake)
+		return nil
 	}
 
-	// Register OpenAI provider
-	openai := providers.NewOpenAIProvider("gpt-4o")
-	if openai.IsAvailable() {
-		c.providers["openai"] = openai
+	// Register configured providers in order; the first available one is active unless the default is
+	for _, settings := range c.settings.Providers {
+		provider := newProvider(settings)
+		if provider == nil || !provider.IsAvailable() {
+			continue
+		}
+
+		if settings.Kind() == "ollama" {
+			provider = installedModel(provider)
+
+			// Embed locally when Ollama is around
+			if c.embedder == nil {
+				embedder := providers.NewOllamaProvider("nomic-embed-text")
+				if settings.BaseURL != "" {
+					embedder.SetBaseURL(settings.BaseURL)
+				}
+				c.embedder = embedder
+			}
+		}
+
+		c.providers[settings.Name] = provider
 		if c.activeModel == "" {
-			c.activeModel = "openai"
+			c.activeModel = settings.Name
 		}
 	}
+	if _, exists := c.providers[c.config.DefaultProvider]; exists {
+		c.activeModel = c.config.DefaultProvider
+	}
 
-	// Register Gemini provider
-	gemini := providers.NewGeminiProvider("gemini-2.0-flash-exp")
-	if gemini.IsAvailable() {
-		c.providers["gemini"] = gemini
-		if c.activeModel == "" {
-			c.activeModel = "gemini"
+	// Register an embedding model; stick to one so vectors stay comparable
+	if c.embedder == nil {
+		if embeddings := providers.NewOpenAIProvider("text-embedding-3-small"); embeddings.IsAvailable() {
+			c.embedder = embeddings
 		}
 	}
 
-	// Register Groq provider
-	groq := providers.NewGroqProvider("llama-3.3-70b-versatile")
-	if groq.IsAvailable() {
-		c.providers["groq"] = groq
-		if c.activeModel == "" {
-			c.activeModel = "groq"
+	// Record or replay provider traffic for offline, deterministic runs
+	if path := os.Getenv("NERO_CASSETTE"); path != "" {
+		if err := c.useCassette(path, providers.CassetteMode(os.Getenv("NERO_CASSETTE_MODE"))); err != nil {
+			return err
 		}
 	}
 
@@ -145,99 +223,445 @@ func (c *Core) Initialize() error {
 		return fmt.Errorf("no AI providers available - install Ollama or set API keys")
 	}
 
+	for _, provider := range c.providers {
+		c.applyTimeout(provider)
+	}
+
 	return nil
 }
 
+// Build a provider from its config entry, or nil for an unknown type
+func newProvider(settings config.ProviderConfig) providers.AIProvider {
+	var provider providers.AIProvider
+	switch settings.Kind() {
+	case "ollama":
+		provider = providers.NewOllamaProvider(settings.Model)
+	case "openai":
+		provider = providers.NewOpenAIProvider(settings.Model)
+	case "groq":
+		provider = providers.NewGroqProvider(settings.Model)
+	case "gemini":
+		provider = providers.NewGeminiProvider(settings.Model)
+	case "anthropic":
+		provider = providers.NewAnthropicProvider(settings.Model)
+	case "compatible":
+		compatible := providers.CompatibleConfig{
+			Name:    settings.Name,
+			BaseURL: settings.BaseURL,
+			Headers: settings.Headers,
+			Model:   settings.Model,
+			Vision:  settings.Vision,
+		}
+		if strings.HasPrefix(settings.Key, "env:") {
+			compatible.APIKeyEnv = strings.TrimPrefix(settings.Key, "env:")
+		}
+		provider = providers.NewCompatibleProvider(compatible)
+	default:
+		return nil
+	}
+
+	if endpoint, ok := provider.(providers.EndpointProvider); ok && settings.BaseURL != "" {
+		endpoint.SetBaseURL(settings.BaseURL)
+	}
+	if keyed, ok := provider.(providers.KeyProvider); ok && settings.Key != "" {
+		keyed.SetAPIKey(credentials.ResolveRef(settings.Name, settings.Key))
+	}
+	return provider
+}
+
+// Wrap every provider in a cassette and add replay-only providers for recorded ones (caller holds the lock)
+func (c *Core) useCassette(path string, mode providers.CassetteMode) error {
+	switch mode {
+	case "":
+		mode = providers.CassetteAuto
+	case providers.CassetteRecord, providers.CassetteReplay, providers.CassetteAuto:
+	default:
+		return fmt.Errorf("invalid NERO_CASSETTE_MODE %q (record, replay or auto)", mode)
+	}
+
+	cassette, err := providers.OpenCassette(path)
+	if err != nil {
+		return err
+	}
+
+	for name, provider := range c.providers {
+		c.providers[name] = cassette.Wrap(provider, mode)
+	}
+
+	// Recorded providers can answer without a key or a running server
+	if mode != providers.CassetteRecord {
+		for _, name := range cassette.Providers() {
+			if _, exists := c.providers[name]; !exists {
+				c.registerProvider(name, cassette.Replay(name))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Register an additional provider at runtime
+func (c *Core) RegisterProvider(name string, provider providers.AIProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registerProvider(name, provider)
+}
+
+// Apply the configured request timeout to providers that support one
+func (c *Core) applyTimeout(provider providers.AIProvider) {
+	if timeoutProvider, ok := provider.(providers.TimeoutProvider); ok && c.config.RequestTimeout > 0 {
+		timeoutProvider.SetTimeout(c.config.RequestTimeout)
+	}
+}
+
+// Probe providers in the background until Close (caller holds the lock)
+func (c *Core) startHealth() {
+	if c.stopHealth != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopHealth = cancel
+	go c.health.Start(ctx, c.config.HealthInterval, c.providerList)
+}
+
+// Stop the background health probes started by Initialize
+func (c *Core) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopHealth != nil {
+		c.stopHealth()
+		c.stopHealth = nil
+	}
+}
+
+// Add a provider and make it eligible for fallback (caller holds the lock)
+func (c *Core) registerProvider(name string, provider providers.AIProvider) {
+	c.applyTimeout(provider)
+
+	known := name == c.config.DefaultProvider
+	for _, fallback := range c.config.FallbackProviders {
+		known = known || fallback == name
+	}
+	if !known {
+		c.config.FallbackProviders = append(c.config.FallbackProviders, name)
+	}
+
+	c.providers[name] = provider
+	if c.activeModel == "" {
+		c.activeModel = name
+	}
+}
+
+// Embed texts with the configured embedding model
+func (c *Core) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embedder := c.Embedder()
+	if embedder == nil {
+		return nil, fmt.Errorf("no embedding provider available")
+	}
+	return embedder.Embed(ctx, texts)
+}
+
+// Get the embedding provider, e.g. to read its dimensions
+func (c *Core) Embedder() providers.EmbeddingProvider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.embedder
+}
+
+// Replace the embedding provider (existing vectors must be re-indexed)
+func (c *Core) SetEmbedder(embedder providers.EmbeddingProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.embedder = embedder
+}
+
 // Process AI request with intelligent provider selection
 func (c *Core) ProcessRequest(ctx context.Context, req *AIRequest) (*AIResponse, error) {
-	provider := c.selectProvider(req)
+	if req.Mode != ModeSingle {
+		return c.processModeRequest(ctx, req)
+	}
+
+	provider, decision, err := c.selectProvider(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 	if provider == nil {
 		return nil, fmt.Errorf("no suitable provider available")
 	}
+	req = routedRequest(req, decision)
+
+	response, err := c.processWithProvider(ctx, req, provider)
+	if err == nil && decision != nil {
+		if response.Metadata == nil {
+			response.Metadata = make(map[string]interface{})
+		}
+		response.Metadata["routing"] = decision
+	}
+	return response, err
+}
 
+// Serve a request from the cache or the chosen provider
+func (c *Core) processWithProvider(ctx context.Context, req *AIRequest, provider providers.AIProvider) (*AIResponse, error) {
 	startTime := time.Now()
+	streaming := req.EnableStream && c.streamingEnabled()
+
+	// Serve repeated requests from the cache
+	cacheKey := c.cacheKey(req, provider)
+	if cacheKey != "" {
+		if cached, hit := c.cache.Get(cacheKey); hit {
+			if streaming {
+				return c.replayCachedStream(ctx, req, provider, cached), nil
+			}
+			return c.cachedResponse(req, provider, cached, startTime), nil
+		}
+	}
 
 	// Handle streaming vs non-streaming
-	if req.EnableStream && c.config.StreamingEnabled {
-		return c.processStreamingRequest(ctx, req, provider)
+	if streaming {
+		return c.processStreamingRequest(ctx, req, provider, cacheKey)
 	}
 
-	return c.processStandardRequest(ctx, req, provider, startTime)
+	return c.processStandardRequest(ctx, req, provider, startTime, cacheKey)
 }
 
 // Process standard (non-streaming) request
-func (c *Core) processStandardRequest(ctx context.Context, req *AIRequest, provider providers.AIProvider, startTime time.Time) (*AIResponse, error) {
+func (c *Core) processStandardRequest(ctx context.Context, req *AIRequest, provider providers.AIProvider, startTime time.Time, cacheKey string) (*AIResponse, error) {
+	response, err := c.sendRequest(ctx, req, provider, startTime)
+	if err != nil {
+		return c.tryFallbackProvider(ctx, req, provider.Name(), err)
+	}
+
+	if cacheKey != "" {
+		c.cache.Set(cacheKey, &CachedResponse{
+			Content:   response.Content,
+			ToolCalls: response.ToolCalls,
+			Model:     response.Model,
+		})
+	}
+	return response, nil
+}
+
+// Send a non-streaming request to a single provider, without fallback
+func (c *Core) sendRequest(ctx context.Context, req *AIRequest, provider providers.AIProvider, startTime time.Time) (*AIResponse, error) {
 	options := &providers.ChatOptions{
-		Temperature:  req.Temperature,
-		MaxTokens:    req.MaxTokens,
-		SystemPrompt: req.SystemPrompt,
-		Stream:       false,
+		Temperature:    req.Temperature,
+		MaxTokens:      req.MaxTokens,
+		SystemPrompt:   req.SystemPrompt,
+		Stream:         false,
+		EnableThoughts: req.EnableThoughts,
+		Tools:          req.Tools,
+		ToolChoice:     req.ToolChoice,
 	}
 
-	response, err := provider.Chat(ctx, req.Messages, options)
+	release, err := c.scheduler.Acquire(ctx, provider.Name(), req.Priority, req.Deadline)
 	if err != nil {
-		return c.tryFallbackProvider(ctx, req, err)
+		return nil, err
+	}
+	defer release()
+	if !c.health.Allow(provider.Name()) {
+		return nil, circuitOpenError(provider.Name())
 	}
 
+	sent := time.Now()
+	response, err := c.chat(ctx, req, provider, options)
+	if err != nil {
+		c.health.RecordFailure(provider.Name(), err)
+		return nil, err
+	}
+	c.health.RecordSuccess(provider.Name(), time.Since(sent))
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+
+	c.recordUsage(req, provider, response.Usage, response.Content)
+
 	return &AIResponse{
 		Content:     response.Content,
+		ToolCalls:   response.ToolCalls,
 		Provider:    provider.Name(),
 		Model:       response.Model,
 		TokensUsed:  response.TokensUsed,
+		Usage:       response.Usage,
 		ProcessTime: time.Since(startTime),
+		HasVision:   c.wantsVision(req),
 		Metadata:    response.Metadata,
 	}, nil
 }
 
-// Process streaming request with real-time capabilities
-func (c *Core) processStreamingRequest(ctx context.Context, req *AIRequest, provider providers.AIProvider) (*AIResponse, error) {
-	streamID := fmt.Sprintf("stream_%d", time.Now().UnixNano())
+// Report whether streaming is switched on
+func (c *Core) streamingEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.StreamingEnabled
+}
+
+// Check whether the request carries images that should go to a vision model
+func (c *Core) wantsVision(req *AIRequest) bool {
+	return req.EnableVision && len(req.Images) > 0 && c.config.VisionEnabled
+}
 
-	streamCtx := &StreamContext{
-		ID:        streamID,
-		Provider:  provider.Name(),
-		StartTime: time.Now(),
-		Channel:   make(chan StreamChunk, 100),
+// Send a chat request, routing it through ChatWithVision when images are attached
+func (c *Core) chat(ctx context.Context, req *AIRequest, provider providers.AIProvider, options *providers.ChatOptions) (*providers.Response, error) {
+	if !c.wantsVision(req) {
+		return provider.Chat(ctx, req.Messages, options)
 	}
 
-	streamCtx.Cancel = func() {
-		close(streamCtx.Channel)
-		c.streamManager.removeStream(streamID)
+	vision, ok := provider.(providers.VisionProvider)
+	if !ok || !vision.SupportsVision() {
+		return nil, fmt.Errorf("provider %s does not support vision", provider.Name())
 	}
 
-	c.streamManager.addStream(streamID, streamCtx)
+	messages, err := providers.AttachImages(req.Messages, req.Images)
+	if err != nil {
+		return nil, err
+	}
+
+	options.EnableVision = true
+	return vision.ChatWithVision(ctx, messages, options)
+}
+
+// Process streaming request with real-time capabilities
+func (c *Core) processStreamingRequest(ctx context.Context, req *AIRequest, provider providers.AIProvider, cacheKey string) (*AIResponse, error) {
+	// The stream outlives this call, so it runs under its own context that CancelStream can end
+	streamCtx, ctx := c.streamManager.start(ctx, provider.Name())
 
 	// Start streaming in goroutine
-	go c.handleStreamingResponse(ctx, req, provider, streamCtx)
+	go c.handleStreamingResponse(ctx, req, provider, streamCtx, cacheKey)
 
 	return &AIResponse{
 		Provider:    provider.Name(),
-		StreamID:    streamID,
+		StreamID:    streamCtx.ID,
+		HasVision:   c.wantsVision(req),
 		HasThoughts: req.EnableThoughts,
 	}, nil
 }
 
-// Handle real-time streaming response
-func (c *Core) handleStreamingResponse(ctx context.Context, req *AIRequest, provider providers.AIProvider, streamCtx *StreamContext) {
+// Handle real-time streaming response, moving to a fallback provider if the current one fails
+func (c *Core) handleStreamingResponse(ctx context.Context, req *AIRequest, provider providers.AIProvider, streamCtx *StreamContext, cacheKey string) {
 	defer close(streamCtx.Channel)
 
+	var answer strings.Builder // Answer text already sent, which a fallback continues from
+	tried := map[string]bool{}
+	current := req
+
+	for {
+		tried[provider.Name()] = true
+
+		result, err := c.streamProvider(ctx, current, provider, streamCtx)
+		answer.WriteString(result.Content)
+		if err == nil {
+			// Only cache answers that came whole from the provider the key was made for
+			if cacheKey != "" && len(tried) == 1 && ctx.Err() == nil {
+				c.cache.Set(cacheKey, result)
+			}
+			return
+		}
+
+		// The caller gave up, or the request itself is bad; other providers cannot help
+		if ctx.Err() != nil {
+			return
+		}
+		if providers.ErrorKindOf(err) == providers.ErrorRequest {
+			streamCtx.Channel <- StreamChunk{Error: err, Done: true}
+			return
+		}
+
+		candidates := c.fallbackCandidates(req, tried)
+		if len(candidates) == 0 {
+			if len(tried) > 1 {
+				err = fmt.Errorf("all providers failed: %w", err)
+			}
+			streamCtx.Channel <- StreamChunk{Error: err, Done: true}
+			return
+		}
+		next := candidates[0]
+
+		resumed := answer.Len() > 0
+		if resumed {
+			current = continuationRequest(req, answer.String())
+		}
+
+		switched := StreamChunk{
+			Type: "switch",
+			Switch: &ProviderSwitch{
+				From:    provider.Name(),
+				To:      next.Name(),
+				Reason:  err.Error(),
+				Resumed: resumed,
+			},
+		}
+		select {
+		case streamCtx.Channel <- switched:
+		case <-ctx.Done():
+			return
+		}
+
+		provider = next
+	}
+}
+
+// Stream one provider's answer into the channel; errors are returned, not sent, so the caller can fail over
+func (c *Core) streamProvider(ctx context.Context, req *AIRequest, provider providers.AIProvider, streamCtx *StreamContext) (*CachedResponse, error) {
+	result := &CachedResponse{Model: providerModel(provider)}
+
+	release, err := c.scheduler.Acquire(ctx, provider.Name(), req.Priority, req.Deadline)
+	if err != nil {
+		return result, err
+	}
+	defer release()
+	if !c.health.Allow(provider.Name()) {
+		return result, circuitOpenError(provider.Name())
+	}
+
 	options := &providers.ChatOptions{
-		Temperature:  req.Temperature,
-		MaxTokens:    req.MaxTokens,
-		SystemPrompt: req.SystemPrompt,
-		Stream:       true,
+		Temperature:    req.Temperature,
+		MaxTokens:      req.MaxTokens,
+		SystemPrompt:   req.SystemPrompt,
+		Stream:         true,
+		EnableThoughts: req.EnableThoughts,
+		Tools:          req.Tools,
+		ToolChoice:     req.ToolChoice,
 	}
 
-	// Check if provider supports streaming
-	if streamer, ok := provider.(providers.StreamingProvider); ok {
-		streamer.ChatStream(ctx, req.Messages, options, func(chunk providers.StreamChunk) {
+	// Check if provider supports streaming (vision requests have no streaming variant)
+	if streamer, ok := provider.(providers.StreamingProvider); ok && !c.wantsVision(req) {
+		var content, text, thoughts strings.Builder
+		var usage *providers.Usage
+		var firstChunk time.Duration
+		var streamErr error
+		sent := time.Now()
+
+		err := streamer.ChatStream(ctx, req.Messages, options, func(chunk providers.StreamChunk) {
+			if firstChunk == 0 {
+				firstChunk = time.Since(sent)
+			}
+
+			// Hold errors back so a fallback can take over
+			if chunk.Error != nil {
+				streamErr = chunk.Error
+				return
+			}
+
+			content.WriteString(chunk.Content)
+			if chunk.IsThought {
+				thoughts.WriteString(chunk.Content)
+			} else {
+				text.WriteString(chunk.Content)
+			}
+			result.ToolCalls = append(result.ToolCalls, chunk.ToolCalls...)
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+
 			streamChunk := StreamChunk{
 				Content:   chunk.Content,
 				Type:      chunk.Type,
 				IsThought: chunk.IsThought,
+				ToolCalls: chunk.ToolCalls,
 				Delta:     chunk.Delta,
 				Done:      chunk.Done,
-				Error:     chunk.Error,
+				Usage:     chunk.Usage,
 			}
 
 			select {
@@ -246,69 +670,325 @@ func (c *Core) handleStreamingResponse(ctx context.Context, req *AIRequest, prov
 				return
 			}
 		})
-	} else {
-		// Fallback: simulate streaming for non-streaming providers
-		response, err := provider.Chat(ctx, req.Messages, options)
+		if err == nil {
+			err = streamErr
+		}
+
+		result.Content = text.String()
+		result.Thoughts = thoughts.String()
 		if err != nil {
-			streamCtx.Channel <- StreamChunk{Error: err, Done: true}
-			return
+			c.health.RecordFailure(provider.Name(), err)
+			return result, err
+		}
+
+		if firstChunk == 0 {
+			firstChunk = time.Since(sent)
+		}
+		c.health.RecordSuccess(provider.Name(), firstChunk)
+		c.recordUsage(req, provider, usage, content.String())
+		return result, nil
+	}
+
+	// Fallback: simulate streaming for non-streaming providers
+	options.Stream = false
+	sent := time.Now()
+	response, err := c.chat(ctx, req, provider, options)
+	if err != nil {
+		c.health.RecordFailure(provider.Name(), err)
+		return result, err
+	}
+	c.health.RecordSuccess(provider.Name(), time.Since(sent))
+	c.recordUsage(req, provider, response.Usage, response.Content)
+
+	result.Content = response.Content
+	result.ToolCalls = response.ToolCalls
+	result.Model = response.Model
+
+	// Simulate word-by-word streaming
+	for _, word := range strings.Fields(response.Content) {
+		select {
+		case streamCtx.Channel <- StreamChunk{Content: word + " ", Type: "text", Delta: true}:
+			time.Sleep(time.Millisecond * 50) // Simulate typing
+		case <-ctx.Done():
+			return result, nil
+		}
+	}
+
+	// Always finish with a final chunk, even for an empty answer
+	select {
+	case streamCtx.Channel <- StreamChunk{ToolCalls: response.ToolCalls, Usage: response.Usage, Done: true}:
+	case <-ctx.Done():
+	}
+	return result, nil
+}
+
+// Ask a fallback provider to pick up a partial answer where the failed provider left off
+func continuationRequest(req *AIRequest, partial string) *AIRequest {
+	resumed := *req
+	resumed.Messages = append(append([]providers.Message(nil), req.Messages...),
+		providers.Message{Role: "assistant", Content: partial},
+		providers.Message{Role: "user", Content: "Your previous answer was cut off. Continue it from exactly where it stopped, without repeating anything or commenting on the interruption."},
+	)
+	return &resumed
+}
+
+// Return the cache key for a request, or "" when the request should not be cached
+func (c *Core) cacheKey(req *AIRequest, provider providers.AIProvider) string {
+	c.mu.RLock()
+	enabled := c.config.CacheEnabled
+	c.mu.RUnlock()
+
+	if c.cache == nil || !(req.Cache || enabled) {
+		return ""
+	}
+
+	// Attached images are read from paths that can change between requests
+	if c.wantsVision(req) {
+		return ""
+	}
+
+	return CacheKey(provider.Name(), providerModel(provider), req)
+}
+
+// Build a response from a cache hit; no tokens were spent, so nothing is recorded in the ledger
+func (c *Core) cachedResponse(req *AIRequest, provider providers.AIProvider, cached *CachedResponse, startTime time.Time) *AIResponse {
+	return &AIResponse{
+		Content:     cached.Content,
+		ToolCalls:   cached.ToolCalls,
+		Provider:    provider.Name(),
+		Model:       cached.Model,
+		ProcessTime: time.Since(startTime),
+		Metadata: map[string]interface{}{
+			"cached":    true,
+			"cached_at": cached.Created,
+		},
+	}
+}
+
+// Replay a cached response as stream chunks
+func (c *Core) replayCachedStream(ctx context.Context, req *AIRequest, provider providers.AIProvider, cached *CachedResponse) *AIResponse {
+	streamCtx, ctx := c.streamManager.start(ctx, provider.Name())
+
+	go func() {
+		defer close(streamCtx.Channel)
+
+		var chunks []StreamChunk
+		if req.EnableThoughts {
+			for _, word := range strings.SplitAfter(cached.Thoughts, " ") {
+				if word != "" {
+					chunks = append(chunks, StreamChunk{Content: word, Type: "reasoning", IsThought: true, Delta: true})
+				}
+			}
+		}
+		for _, word := range strings.SplitAfter(cached.Content, " ") {
+			if word != "" {
+				chunks = append(chunks, StreamChunk{Content: word, Type: "text", Delta: true})
+			}
 		}
+		chunks = append(chunks, StreamChunk{ToolCalls: cached.ToolCalls, Done: true})
 
-		// Simulate word-by-word streaming
-		words := strings.Fields(response.Content)
-		for i, word := range words {
+		for _, chunk := range chunks {
 			select {
-			case streamCtx.Channel <- StreamChunk{
-				Content: word + " ",
-				Type:    "text",
-				Delta:   true,
-				Done:    i == len(words)-1,
-			}:
-				time.Sleep(time.Millisecond * 50) // Simulate typing
+			case streamCtx.Channel <- chunk:
 			case <-ctx.Done():
 				return
 			}
 		}
+	}()
+
+	return &AIResponse{
+		Provider:    provider.Name(),
+		Model:       cached.Model,
+		StreamID:    streamCtx.ID,
+		HasThoughts: req.EnableThoughts,
+		Metadata: map[string]interface{}{
+			"cached":    true,
+			"cached_at": cached.Created,
+		},
+	}
+}
+
+// Turn response caching on or off for every request
+func (c *Core) SetCacheEnabled(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.CacheEnabled = enabled
+}
+
+// Return the response cache
+func (c *Core) Cache() *ResponseCache {
+	return c.cache
+}
+
+// Append a request's token usage to the ledger; bookkeeping failures never fail the request
+func (c *Core) recordUsage(req *AIRequest, provider providers.AIProvider, usage *providers.Usage, content string) {
+	if c.ledger == nil {
+		return
 	}
+
+	model := providerModel(provider)
+
+	prompt := req.SystemPrompt
+	for _, msg := range req.Messages {
+		prompt += msg.Content
+	}
+
+	c.ledger.Record(NewUsageRecord(provider.Name(), model, usage, prompt, content))
 }
 
-// Get streaming channel for real-time updates
-func (c *Core) GetStreamChannel(streamID string) (<-chan StreamChunk, bool) {
-	return c.streamManager.getStreamChannel(streamID)
+// Return the model a provider is bound to, if it reports one
+func providerModel(provider providers.AIProvider) string {
+	if modelProvider, ok := provider.(providers.ModelProvider); ok {
+		return modelProvider.Model()
+	}
+	return ""
+}
+
+// Return the usage ledger
+func (c *Core) Ledger() *Ledger {
+	return c.ledger
 }
 
 // Try fallback providers on failure
-func (c *Core) tryFallbackProvider(ctx context.Context, req *AIRequest, originalErr error) (*AIResponse, error) {
-	c.mu.RLock()
-	fallbacks := c.config.FallbackProviders
-	c.mu.RUnlock()
+func (c *Core) tryFallbackProvider(ctx context.Context, req *AIRequest, failed string, originalErr error) (*AIResponse, error) {
+	// The caller gave up, so other providers cannot help
+	if ctx.Err() != nil {
+		return nil, originalErr
+	}
+
+	// A malformed request fails the same way everywhere; don't spend other providers' quota on it
+	kind := providers.ErrorKindOf(originalErr)
+	if kind == providers.ErrorRequest {
+		return nil, originalErr
+	}
 
-	for _, providerName := range fallbacks {
-		if provider, exists := c.providers[providerName]; exists && provider.Name() != req.Provider {
-			if response, err := c.processStandardRequest(ctx, req, provider, time.Now()); err == nil {
-				response.Metadata["fallback_from"] = req.Provider
-				response.Metadata["original_error"] = originalErr.Error()
-				return response, nil
+	for _, provider := range c.fallbackCandidates(req, map[string]bool{failed: true}) {
+		response, err := c.sendRequest(ctx, req, provider, time.Now())
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, originalErr
 			}
+			continue
+		}
+
+		response.Metadata["fallback_from"] = failed
+		response.Metadata["original_error"] = originalErr.Error()
+		if kind != "" {
+			response.Metadata["error_kind"] = string(kind)
 		}
+		return response, nil
 	}
 
 	return nil, fmt.Errorf("all providers failed: %w", originalErr)
 }
 
-// Select best provider for request
-func (c *Core) selectProvider(req *AIRequest) providers.AIProvider {
+// List healthy fallback providers able to serve the request, skipping those already tried
+func (c *Core) fallbackCandidates(req *AIRequest, tried map[string]bool) []providers.AIProvider {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	var candidates []providers.AIProvider
+	listed := make(map[string]bool)
+	for _, name := range c.config.FallbackProviders {
+		provider, exists := c.providers[name]
+		if !exists || tried[name] || tried[provider.Name()] || listed[name] || !c.health.Healthy(name) {
+			continue
+		}
+		listed[name] = true
+
+		// Private requests never fall back to a provider off this machine
+		if isPrivate(req) && !c.isLocal(name) && (explicitlyPrivate(req) || c.hasLocalProvider()) {
+			continue
+		}
+
+		// Only hand the request to providers that can serve it
+		if c.wantsVision(req) && !supportsVision(provider) {
+			continue
+		}
+		if toolProvider, ok := provider.(providers.ToolProvider); len(req.Tools) > 0 && (!ok || !toolProvider.SupportsTools()) {
+			continue
+		}
+
+		candidates = append(candidates, provider)
+	}
+	return candidates
+}
+
+// Select best provider for request, letting the routing policy decide unless the request names one
+func (c *Core) selectProvider(ctx context.Context, req *AIRequest) (providers.AIProvider, *RoutingDecision, error) {
+	c.mu.RLock()
+	policy := c.router
+	requested, exists := c.providers[req.Provider]
+	c.mu.RUnlock()
+
 	// Use specified provider if available
-	if req.Provider != "" {
-		if provider, exists := c.providers[req.Provider]; exists {
+	if req.Provider != "" && exists {
+		return c.withModel(requested, req.Model), nil, nil
+	}
+
+	if policy != nil {
+		decision, err := policy.Route(ctx, req, c.routeCandidates())
+		if err != nil {
+			return nil, decision, err
+		}
+
+		c.mu.RLock()
+		provider, exists := c.providers[decision.Provider]
+		c.mu.RUnlock()
+		if exists {
+			return c.withModel(provider, decision.Model), decision, nil
+		}
+	}
+
+	return c.defaultProvider(req), nil, nil
+}
+
+// Copy the request with the routing decision's task, leaving the caller's request untouched
+func routedRequest(req *AIRequest, decision *RoutingDecision) *AIRequest {
+	if decision == nil {
+		return req
+	}
+	routed := *req
+	routed.routedTask = decision.Task
+	return &routed
+}
+
+// Bind a provider to another model for one request, when it can switch; the clone shares
+// the original's transport and so already has its timeout
+func (c *Core) withModel(provider providers.AIProvider, model string) providers.AIProvider {
+	if model == "" || model == providerModel(provider) {
+		return provider
+	}
+
+	switcher, ok := provider.(providers.ModelSwitcher)
+	if !ok {
+		return provider
+	}
+	return switcher.WithModel(model)
+}
+
+// Select the active provider, or the first healthy fallback, without a routing policy
+func (c *Core) defaultProvider(req *AIRequest) providers.AIProvider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Images need a vision model: keep the active provider if it can see, else try fallbacks
+	if c.wantsVision(req) {
+		for _, name := range append([]string{c.activeModel}, c.config.FallbackProviders...) {
+			if provider, exists := c.providers[name]; exists && supportsVision(provider) && c.health.Healthy(name) {
+				return provider
+			}
+		}
+	}
+
+	// Use active provider while it is healthy, else the first healthy fallback
+	for _, name := range append([]string{c.activeModel}, c.config.FallbackProviders...) {
+		if provider, exists := c.providers[name]; exists && c.health.Healthy(name) {
 			return provider
 		}
 	}
 
-	// Use active provider
+	// Everything is failing; the active provider is as good a bet as any
 	if provider, exists := c.providers[c.activeModel]; exists {
 		return provider
 	}
@@ -321,6 +1001,53 @@ func (c *Core) selectProvider(req *AIRequest) providers.AIProvider {
 	return nil
 }
 
+// Wrap a provider so its calls wait their turn, e.g. helper models at PriorityBackground
+func (c *Core) ScheduledProvider(name string, priority Priority) (providers.AIProvider, bool) {
+	c.mu.RLock()
+	provider, exists := c.providers[name]
+	c.mu.RUnlock()
+
+	if !exists {
+		return nil, false
+	}
+	return newScheduledProvider(provider, c.scheduler, priority), true
+}
+
+// Report running and queued requests for a provider
+func (c *Core) QueueStats(name string) QueueStats {
+	return c.scheduler.Stats(name)
+}
+
+// Copy the registered providers for the health monitor
+func (c *Core) providerList() map[string]providers.AIProvider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	list := make(map[string]providers.AIProvider, len(c.providers))
+	for name, provider := range c.providers {
+		list[name] = provider
+	}
+	return list
+}
+
+// Report the health of every registered provider, sorted by name
+func (c *Core) ProviderHealth() []ProviderHealth {
+	names := c.GetAvailableProviders()
+	sort.Strings(names)
+
+	health := make([]ProviderHealth, len(names))
+	for i, name := range names {
+		health[i] = c.health.Health(name)
+	}
+	return health
+}
+
+// Check whether a provider can accept image inputs
+func supportsVision(provider providers.AIProvider) bool {
+	vision, ok := provider.(providers.VisionProvider)
+	return ok && vision.SupportsVision()
+}
+
 // Switch active provider
 func (c *Core) SwitchProvider(name string) error {
 	c.mu.Lock()
@@ -334,6 +1061,106 @@ func (c *Core) SwitchProvider(name string) error {
 	return nil
 }
 
+// List the models a registered provider can serve
+func (c *Core) ListModels(ctx context.Context, name string) ([]providers.ModelInfo, error) {
+	c.mu.RLock()
+	provider, exists := c.providers[name]
+	c.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("provider %s not available", name)
+	}
+
+	lister, ok := provider.(providers.ModelLister)
+	if !ok {
+		return nil, fmt.Errorf("provider %s cannot list models", name)
+	}
+	return lister.ListModels(ctx)
+}
+
+// Switch the active provider and bind it to another model, without restarting
+func (c *Core) SwitchModel(ctx context.Context, name, model string) error {
+	c.mu.RLock()
+	provider, exists := c.providers[name]
+	c.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("provider %s not available", name)
+	}
+
+	switcher, ok := provider.(providers.ModelSwitcher)
+	if !ok {
+		return fmt.Errorf("provider %s cannot switch models", name)
+	}
+
+	// Catch typos early; if the listing itself fails, let the first request report the problem
+	if lister, ok := provider.(providers.ModelLister); ok {
+		if models, err := lister.ListModels(ctx); err == nil {
+			found := false
+			for _, info := range models {
+				if providers.ModelMatches(info.Name, model) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("model %s not found on %s - see /models %s", model, name, name)
+			}
+		}
+	}
+
+	switched := switcher.WithModel(model)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.applyTimeout(switched)
+	c.providers[name] = switched
+	c.activeModel = name
+	return nil
+}
+
+// Get the model a provider is currently bound to
+func (c *Core) GetModel(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if modelProvider, ok := c.providers[name].(providers.ModelProvider); ok {
+		return modelProvider.Model()
+	}
+	return ""
+}
+
+// Fall back to an installed chat model when the default is not pulled locally
+func installedModel(provider providers.AIProvider) providers.AIProvider {
+	lister, canList := provider.(providers.ModelLister)
+	switcher, canSwitch := provider.(providers.ModelSwitcher)
+	modelProvider, hasModel := provider.(providers.ModelProvider)
+	if !canList || !canSwitch || !hasModel {
+		return provider
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	models, err := lister.ListModels(ctx)
+	if err != nil {
+		return provider
+	}
+
+	for _, info := range models {
+		if providers.ModelMatches(info.Name, modelProvider.Model()) {
+			return provider
+		}
+	}
+	for _, info := range models {
+		if !strings.Contains(info.Name, "embed") {
+			return switcher.WithModel(info.Name)
+		}
+	}
+	return provider
+}
+
 // Get available providers
 func (c *Core) GetAvailableProviders() []string {
 	c.mu.RLock()
@@ -352,35 +1179,3 @@ func (c *Core) GetActiveProvider() string {
 	defer c.mu.RUnlock()
 	return c.activeModel
 }
-
-// Create stream manager
-func NewStreamManager() *StreamManager {
-	return &StreamManager{
-		activeStreams: make(map[string]*StreamContext),
-	}
-}
-
-// Add new stream
-func (sm *StreamManager) addStream(id string, ctx *StreamContext) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	sm.activeStreams[id] = ctx
-}
-
-// Remove stream
-func (sm *StreamManager) removeStream(id string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	delete(sm.activeStreams, id)
-}
-
-// Get stream channel
-func (sm *StreamManager) getStreamChannel(id string) (<-chan StreamChunk, bool) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if ctx, exists := sm.activeStreams[id]; exists {
-		return ctx.Channel, true
-	}
-	return nil, false
-}
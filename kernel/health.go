@@ -0,0 +1,273 @@
+package kernel
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"nero/providers"
+)
+
+// Describe whether a provider's circuit lets requests through
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // Healthy; requests flow normally
+	CircuitOpen     CircuitState = "open"      // Failing; requests skip the provider until the cooldown ends
+	CircuitHalfOpen CircuitState = "half-open" // Cooldown over; a single trial request decides
+)
+
+// Number of recent requests the error rate is computed over
+const healthWindow = 20
+
+// Report a provider's recent health
+type ProviderHealth struct {
+	Provider            string
+	State               CircuitState
+	Available           bool          // Result of the last availability probe
+	Latency             time.Duration // Moving average of successful requests
+	ErrorRate           float64       // Failures among the last requests, 0-1
+	Requests            int
+	ConsecutiveFailures int
+	LastSuccess         time.Time
+	LastFailure         time.Time
+	LastError           string
+	LastProbe           time.Time
+}
+
+// Track provider health and trip a circuit breaker on repeated failures
+type HealthMonitor struct {
+	providers map[string]*healthState
+	threshold int           // Consecutive failures that open the circuit
+	cooldown  time.Duration // Time an open circuit waits before half-opening
+	mu        sync.RWMutex
+}
+
+type healthState struct {
+	health   ProviderHealth
+	outcomes []bool // Ring of recent results, true for failures
+	next     int
+	openedAt time.Time
+	open     bool
+	trial    time.Time // When the half-open trial request started; zero when none is running
+}
+
+// Create a health monitor
+func NewHealthMonitor(threshold int, cooldown time.Duration) *HealthMonitor {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &HealthMonitor{
+		providers: make(map[string]*healthState),
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Return the state for a provider, creating it on first use; callers hold the lock
+func (hm *HealthMonitor) state(name string) *healthState {
+	state, exists := hm.providers[name]
+	if !exists {
+		state = &healthState{health: ProviderHealth{Provider: name, Available: true}}
+		hm.providers[name] = state
+	}
+	return state
+}
+
+// Record a successful request, closing the circuit
+func (hm *HealthMonitor) RecordSuccess(name string, latency time.Duration) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	state := hm.state(name)
+	state.record(false)
+	state.open = false
+	state.trial = time.Time{}
+	state.health.ConsecutiveFailures = 0
+	state.health.LastSuccess = time.Now()
+	state.health.Available = true
+
+	if state.health.Latency == 0 {
+		state.health.Latency = latency
+	} else {
+		state.health.Latency = (state.health.Latency*4 + latency) / 5
+	}
+}
+
+// Record a failed request; enough of them in a row open the circuit
+func (hm *HealthMonitor) RecordFailure(name string, err error) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	// A trial that says nothing about the provider lets the next request try instead
+	if state, exists := hm.providers[name]; exists {
+		state.trial = time.Time{}
+	}
+	if !countsAsFailure(err) {
+		return
+	}
+
+	state := hm.state(name)
+	state.record(true)
+	state.health.ConsecutiveFailures++
+	state.health.LastFailure = time.Now()
+	state.health.LastError = err.Error()
+
+	// A failed trial while half-open reopens at once
+	if state.health.ConsecutiveFailures >= hm.threshold || state.open {
+		state.open = true
+		state.openedAt = time.Now()
+	}
+}
+
+// Record the result of an availability probe
+func (hm *HealthMonitor) RecordProbe(name string, available bool) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	state := hm.state(name)
+	state.health.Available = available
+	state.health.LastProbe = time.Now()
+}
+
+// Report whether requests should be sent to a provider
+func (hm *HealthMonitor) Healthy(name string) bool {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
+	state, exists := hm.providers[name]
+	if !exists {
+		return true
+	}
+	return state.health.Available && hm.circuit(state) != CircuitOpen
+}
+
+// Claim the right to send a provider a request: always while its circuit is closed, and
+// once it half-opens, only for a single trial until RecordSuccess or RecordFailure
+func (hm *HealthMonitor) Allow(name string) bool {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	state, exists := hm.providers[name]
+	if !exists {
+		return true
+	}
+	switch hm.circuit(state) {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		state.trial = time.Now()
+		return true
+	}
+	return false
+}
+
+// Return a provider's health snapshot
+func (hm *HealthMonitor) Health(name string) ProviderHealth {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
+	state, exists := hm.providers[name]
+	if !exists {
+		return ProviderHealth{Provider: name, State: CircuitClosed, Available: true}
+	}
+
+	health := state.health
+	health.State = hm.circuit(state)
+	health.ErrorRate = state.errorRate()
+	return health
+}
+
+// Return snapshots for every provider seen so far, sorted by name
+func (hm *HealthMonitor) Snapshot() []ProviderHealth {
+	hm.mu.RLock()
+	names := make([]string, 0, len(hm.providers))
+	for name := range hm.providers {
+		names = append(names, name)
+	}
+	hm.mu.RUnlock()
+
+	sort.Strings(names)
+	snapshot := make([]ProviderHealth, len(names))
+	for i, name := range names {
+		snapshot[i] = hm.Health(name)
+	}
+	return snapshot
+}
+
+// Probe providers now and then on an interval until the context ends
+func (hm *HealthMonitor) Start(ctx context.Context, interval time.Duration, list func() map[string]providers.AIProvider) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for name, provider := range list() {
+			if ctx.Err() != nil {
+				return
+			}
+			hm.RecordProbe(name, provider.IsAvailable())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Work out the circuit state; an open circuit half-opens once the cooldown passes, and
+// stays open to everyone else while its trial request runs (or until a stuck one times out)
+func (hm *HealthMonitor) circuit(state *healthState) CircuitState {
+	switch {
+	case !state.open:
+		return CircuitClosed
+	case time.Since(state.openedAt) < hm.cooldown:
+		return CircuitOpen
+	case !state.trial.IsZero() && time.Since(state.trial) < hm.cooldown:
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
+
+func (s *healthState) record(failed bool) {
+	if len(s.outcomes) < healthWindow {
+		s.outcomes = append(s.outcomes, failed)
+	} else {
+		s.outcomes[s.next] = failed
+		s.next = (s.next + 1) % healthWindow
+	}
+	s.health.Requests++
+}
+
+func (s *healthState) errorRate() float64 {
+	if len(s.outcomes) == 0 {
+		return 0
+	}
+	failures := 0
+	for _, failed := range s.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(s.outcomes))
+}
+
+// Report a provider skipped because its circuit is open, so the caller falls back
+func circuitOpenError(name string) error {
+	return &providers.ProviderError{Provider: name, Kind: providers.ErrorServer, Message: "circuit open after repeated failures"}
+}
+
+// Ignore failures that say nothing about the provider's health: cancellations, bad requests and models lacking a feature
+func countsAsFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	switch providers.ErrorKindOf(err) {
+	case providers.ErrorRequest, providers.ErrorContextLength, providers.ErrorUnsupported:
+		return false
+	}
+	return true
+}
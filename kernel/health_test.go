@@ -0,0 +1,64 @@
+package kernel
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHalfOpenAdmitsOneTrial(t *testing.T) {
+	health := NewHealthMonitor(2, 10*time.Millisecond)
+	failure := errors.New("connection refused")
+
+	health.RecordFailure("flaky", failure)
+	health.RecordFailure("flaky", failure)
+	if health.Allow("flaky") || health.Healthy("flaky") {
+		t.Fatal("open circuit let a request through")
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	if !health.Healthy("flaky") {
+		t.Fatal("circuit did not half-open after the cooldown")
+	}
+
+	// Concurrent requests race for the trial; only one may win
+	var admitted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if health.Allow("flaky") {
+				admitted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := admitted.Load(); got != 1 {
+		t.Fatalf("half-open circuit admitted %d requests, want 1", got)
+	}
+	if health.Healthy("flaky") {
+		t.Error("provider looks healthy while its trial runs")
+	}
+
+	health.RecordSuccess("flaky", time.Millisecond)
+	if state := health.Health("flaky").State; state != CircuitClosed || !health.Allow("flaky") {
+		t.Errorf("state = %s after a successful trial, want closed", state)
+	}
+}
+
+func TestFailedTrialReopens(t *testing.T) {
+	health := NewHealthMonitor(1, 10*time.Millisecond)
+	health.RecordFailure("flaky", errors.New("503"))
+
+	time.Sleep(15 * time.Millisecond)
+	if !health.Allow("flaky") {
+		t.Fatal("half-open circuit refused the trial")
+	}
+	health.RecordFailure("flaky", errors.New("503"))
+	if state := health.Health("flaky").State; state != CircuitOpen {
+		t.Errorf("state = %s after a failed trial, want open", state)
+	}
+}
@@ -0,0 +1,402 @@
+package kernel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nero/providers"
+)
+
+// Choose how a request is spread across providers
+type RequestMode string
+
+const (
+	ModeSingle  RequestMode = ""        // One provider, moving to fallbacks on failure
+	ModeRace    RequestMode = "race"    // Ask several providers at once; the first complete answer wins
+	ModeHedge   RequestMode = "hedge"   // Start a backup provider only if the first is slow to produce a token
+	ModeCompare RequestMode = "compare" // Ask several providers and return every answer; never streamed
+)
+
+// Providers asked by race and compare requests that don't set Fanout
+const defaultFanout = 2
+
+// One provider's part in a race, hedge or compare request
+type attempt struct {
+	provider providers.AIProvider
+	cancel   context.CancelFunc
+
+	// Non-streaming result
+	response *AIResponse
+
+	// Streamed chunks, held back until the attempt wins
+	buffer []StreamChunk
+
+	err error
+}
+
+// Report a streamed chunk from an attempt, or that it finished when finished is set
+type attemptEvent struct {
+	attempt  *attempt
+	chunk    StreamChunk
+	finished bool
+}
+
+// Run a race, hedge or compare request
+func (c *Core) processModeRequest(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+	switch req.Mode {
+	case ModeRace, ModeHedge, ModeCompare:
+	default:
+		return nil, fmt.Errorf("unknown request mode %q (race, hedge or compare)", req.Mode)
+	}
+
+	candidates, decision, err := c.modeCandidates(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Mode != ModeCompare && req.EnableStream && c.streamingEnabled() {
+		streamCtx, ctx := c.streamManager.start(ctx, candidates[0].Name())
+		go c.handleModeStream(ctx, req, candidates, streamCtx)
+
+		response := &AIResponse{
+			Provider:    candidates[0].Name(),
+			StreamID:    streamCtx.ID,
+			HasThoughts: req.EnableThoughts,
+			Metadata:    modeMetadata(req, candidates),
+		}
+		if decision != nil {
+			response.Metadata["routing"] = decision
+		}
+		return response, nil
+	}
+
+	response, err := c.runModeRequest(ctx, req, candidates)
+	if err == nil && decision != nil {
+		response.Metadata["routing"] = decision
+	}
+	return response, err
+}
+
+// Pick the providers a mode request uses, in the order they are tried; the routing policy picks the first
+func (c *Core) modeCandidates(ctx context.Context, req *AIRequest) ([]providers.AIProvider, *RoutingDecision, error) {
+	var candidates []providers.AIProvider
+	var decision *RoutingDecision
+
+	if len(req.Providers) > 0 {
+		c.mu.RLock()
+		for _, name := range req.Providers {
+			provider, exists := c.providers[name]
+			if !exists {
+				c.mu.RUnlock()
+				return nil, nil, fmt.Errorf("unknown provider %q", name)
+			}
+			candidates = append(candidates, provider)
+		}
+		c.mu.RUnlock()
+	} else {
+		first, routed, err := c.selectProvider(ctx, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		if first == nil {
+			return nil, nil, fmt.Errorf("no suitable provider available")
+		}
+		decision = routed
+		req = routedRequest(req, decision)
+		candidates = append([]providers.AIProvider{first}, c.fallbackCandidates(req, map[string]bool{first.Name(): true})...)
+	}
+
+	// Hedging only ever adds one backup
+	limit := req.Fanout
+	if req.Mode == ModeHedge {
+		limit = 2
+	} else if limit < 1 {
+		limit = defaultFanout
+	}
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, decision, nil
+}
+
+// Return how long a hedged request waits before starting its backup
+func (c *Core) hedgeDelay(req *AIRequest) time.Duration {
+	if req.HedgeAfter > 0 {
+		return req.HedgeAfter
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.HedgeAfter
+}
+
+// Describe a mode request on its response
+func modeMetadata(req *AIRequest, candidates []providers.AIProvider) map[string]interface{} {
+	names := make([]string, len(candidates))
+	for i, provider := range candidates {
+		names[i] = provider.Name()
+	}
+	return map[string]interface{}{
+		"mode":      string(req.Mode),
+		"providers": names,
+	}
+}
+
+// Run a non-streaming race, hedge or compare request
+func (c *Core) runModeRequest(ctx context.Context, req *AIRequest, candidates []providers.AIProvider) (*AIResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	results := make(chan *attempt, len(candidates))
+	var attempts []*attempt
+
+	launch := func() {
+		a := &attempt{provider: candidates[len(attempts)]}
+		attempts = append(attempts, a)
+
+		var attemptCtx context.Context
+		attemptCtx, a.cancel = context.WithCancel(ctx)
+		go func() {
+			a.response, a.err = c.sendRequest(attemptCtx, req, a.provider, startTime)
+			results <- a
+		}()
+	}
+
+	// Race and compare ask everyone at once; hedge starts with one
+	launch()
+	if req.Mode != ModeHedge {
+		for len(attempts) < len(candidates) {
+			launch()
+		}
+	}
+
+	var hedge <-chan time.Time
+	if req.Mode == ModeHedge && len(attempts) < len(candidates) {
+		timer := time.NewTimer(c.hedgeDelay(req))
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	metadata := modeMetadata(req, candidates)
+	failed := make(map[string]string)
+	var lastErr error
+
+	for pending := len(attempts); pending > 0; {
+		select {
+		case a := <-results:
+			pending--
+			if a.err != nil {
+				lastErr = a.err
+				failed[a.provider.Name()] = a.err.Error()
+
+				// A failed hedge starts the backup without waiting
+				if hedge != nil && ctx.Err() == nil {
+					hedge = nil
+					launch()
+					pending++
+				}
+				continue
+			}
+
+			if req.Mode == ModeCompare {
+				continue
+			}
+
+			// First complete answer wins; stop the rest
+			for _, other := range attempts {
+				if other != a {
+					other.cancel()
+				}
+			}
+			for key, value := range metadata {
+				a.response.Metadata[key] = value
+			}
+			if req.Mode == ModeHedge {
+				a.response.Metadata["hedged"] = len(attempts) > 1
+			}
+			if len(failed) > 0 {
+				a.response.Metadata["failed"] = failed
+			}
+			a.response.ProcessTime = time.Since(startTime)
+			return a.response, nil
+
+		case <-hedge:
+			hedge = nil
+			launch()
+			pending++
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// Every attempt has reported; keep the answers in the order the providers were asked
+	var answers []*AIResponse
+	for _, a := range attempts {
+		if a.err == nil {
+			answers = append(answers, a.response)
+		}
+	}
+	if len(answers) == 0 {
+		return nil, fmt.Errorf("all providers failed: %w", lastErr)
+	}
+
+	response := *answers[0]
+	response.Answers = answers
+	response.ProcessTime = time.Since(startTime)
+	response.Metadata = metadata
+	if len(failed) > 0 {
+		response.Metadata["failed"] = failed
+	}
+	return &response, nil
+}
+
+// Stream a race or hedge request: race forwards the first complete answer, hedge the first to produce a token
+func (c *Core) handleModeStream(ctx context.Context, req *AIRequest, candidates []providers.AIProvider, streamCtx *StreamContext) {
+	defer close(streamCtx.Channel)
+
+	events := make(chan attemptEvent)
+	var attempts []*attempt
+
+	launch := func() {
+		a := &attempt{provider: candidates[len(attempts)]}
+		attempts = append(attempts, a)
+
+		var attemptCtx context.Context
+		attemptCtx, a.cancel = context.WithCancel(ctx)
+		chunks := make(chan StreamChunk, 100)
+
+		go func() {
+			defer close(chunks)
+			_, a.err = c.streamProvider(attemptCtx, req, a.provider, &StreamContext{Channel: chunks})
+		}()
+
+		// Relay the attempt's chunks until it is cancelled; draining continues so the provider never blocks
+		go func() {
+			for chunk := range chunks {
+				select {
+				case events <- attemptEvent{attempt: a, chunk: chunk}:
+				case <-attemptCtx.Done():
+				}
+			}
+			select {
+			case events <- attemptEvent{attempt: a, finished: true}:
+			case <-attemptCtx.Done():
+			}
+		}()
+	}
+	defer func() {
+		for _, a := range attempts {
+			a.cancel()
+		}
+	}()
+
+	launch()
+	if req.Mode == ModeRace {
+		for len(attempts) < len(candidates) {
+			launch()
+		}
+	}
+
+	var hedge <-chan time.Time
+	if req.Mode == ModeHedge && len(attempts) < len(candidates) {
+		timer := time.NewTimer(c.hedgeDelay(req))
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	// Wait for a winner, holding every attempt's chunks back
+	var winner *attempt
+	finished := false
+	var lastErr error
+	for pending := len(attempts); winner == nil; {
+		select {
+		case event := <-events:
+			a := event.attempt
+			if !event.finished {
+				a.buffer = append(a.buffer, event.chunk)
+				if req.Mode == ModeHedge && (event.chunk.Content != "" || len(event.chunk.ToolCalls) > 0) {
+					winner = a
+				}
+				continue
+			}
+
+			pending--
+			if a.err == nil {
+				winner, finished = a, true
+				continue
+			}
+			lastErr = a.err
+
+			// A failed hedge starts the backup without waiting
+			if hedge != nil {
+				hedge = nil
+				launch()
+				pending++
+			}
+			if pending == 0 {
+				if len(attempts) > 1 {
+					lastErr = fmt.Errorf("all providers failed: %w", lastErr)
+				}
+				select {
+				case streamCtx.Channel <- StreamChunk{Error: lastErr, Done: true}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+		case <-hedge:
+			hedge = nil
+			launch()
+			pending++
+
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	for _, a := range attempts {
+		if a != winner {
+			a.cancel()
+		}
+	}
+	streamCtx.setProvider(winner.provider.Name())
+
+	for _, chunk := range winner.buffer {
+		select {
+		case streamCtx.Channel <- chunk:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	// Hedge winners are still streaming; forward the rest as it arrives
+	for !finished {
+		select {
+		case event := <-events:
+			if event.attempt != winner {
+				continue
+			}
+			if event.finished {
+				finished = true
+				if winner.err != nil && ctx.Err() == nil {
+					select {
+					case streamCtx.Channel <- StreamChunk{Error: winner.err, Done: true}:
+					case <-ctx.Done():
+					}
+				}
+				continue
+			}
+			select {
+			case streamCtx.Channel <- event.chunk:
+			case <-ctx.Done():
+				return
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
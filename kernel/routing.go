@@ -0,0 +1,539 @@
+package kernel
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"nero/config"
+	"nero/providers"
+)
+
+// Describe the kind of work a request asks for
+type TaskClass string
+
+const (
+	TaskGeneral     TaskClass = "general"      // Anything without a stronger signal
+	TaskQuick       TaskClass = "quick"        // Short chat that should come back fast
+	TaskCode        TaskClass = "code"         // Writing, reading or debugging code
+	TaskLongContext TaskClass = "long_context" // Prompts too big for small context windows
+	TaskVision      TaskClass = "vision"       // Attached images
+	TaskPrivate     TaskClass = "private"      // Secrets or personal data that must stay on this machine
+)
+
+// Prompts estimated above this many tokens are long-context work
+const longContextTokens = 8000
+
+// Time a helper model gets to label a request before heuristics take over
+const classifyTimeout = 3 * time.Second
+
+// Context windows by longest model-name prefix, for providers whose config doesn't declare one
+var defaultContextWindows = map[string]int{
+	"gpt-4o":                  128_000,
+	"gpt-4.1":                 1_000_000,
+	"gpt-5":                   400_000,
+	"o3":                      200_000,
+	"o4-mini":                 200_000,
+	"claude":                  200_000,
+	"gemini-1.5":              1_000_000,
+	"gemini-2":                1_000_000,
+	"llama-3.3-70b-versatile": 128_000,
+	"llama-3.1-8b-instant":    128_000,
+}
+
+// Context window assumed for local models, which Ollama runs with small defaults
+const localContextWindow = 8192
+
+var (
+	privatePattern = regexp.MustCompile(`(?i)\b(password|passwd|private key|secret key|api[_ -]?key|ssn|social security|credit card|medical|diagnosis|confidential)\b|-----BEGIN [A-Z ]*PRIVATE KEY-----|\bsk-[A-Za-z0-9_-]{16,}|\bAKIA[0-9A-Z]{16}\b`)
+	codePattern    = regexp.MustCompile("(?i)```|\\b(func|def|class|import|package|return|const|struct|interface)\\b|\\b(stack ?trace|traceback|panic:|segfault|compile|refactor|regex|sql|bug|function|variable)\\b|\\.(go|py|js|ts|rs|java|c|cpp|rb|sh)\\b")
+)
+
+// Label a request with the kind of work it asks for
+type TaskClassifier interface {
+	Classify(ctx context.Context, req *AIRequest) Classification
+}
+
+// Report a request's task and what decided it
+type Classification struct {
+	Task TaskClass
+	By   string // "request", "heuristic" or "helper:<provider>"
+}
+
+// Describe a provider routing can choose
+type RouteCandidate struct {
+	Name          string
+	Model         string
+	Active        bool
+	Position      int // Order among the active provider, the default and fallbacks
+	Local         bool
+	Vision        bool
+	Tools         bool
+	ContextWindow int         // Tokens; 0 when unknown
+	Tasks         []TaskClass // Tasks the config prefers this provider for
+	Price         Price       // USD per million tokens
+	Priced        bool        // Price is known; local providers are free
+	Latency       time.Duration
+	Healthy       bool
+}
+
+// Record why a request went to a provider; stored in AIResponse.Metadata["routing"]
+type RoutingDecision struct {
+	Task       TaskClass         `json:"task"`
+	Classifier string            `json:"classifier"`
+	Provider   string            `json:"provider"`
+	Model      string            `json:"model,omitempty"`
+	Rule       int               `json:"rule,omitempty"` // 1-based index of the config rule that chose the provider
+	Reason     string            `json:"reason"`
+	Rejected   map[string]string `json:"rejected,omitempty"` // Providers ruled out, with why
+}
+
+// Choose a provider for a request from the candidates Core offers
+type RoutingPolicy interface {
+	Route(ctx context.Context, req *AIRequest, candidates []RouteCandidate) (*RoutingDecision, error)
+}
+
+// Classify requests from their content alone
+type HeuristicClassifier struct{}
+
+func (HeuristicClassifier) Classify(ctx context.Context, req *AIRequest) Classification {
+	if req.Task != "" {
+		return Classification{Task: req.Task, By: "request"}
+	}
+	if task, ok := hardTask(req); ok {
+		return Classification{Task: task, By: "heuristic"}
+	}
+
+	text := lastUserMessage(req.Messages)
+	switch {
+	case codePattern.MatchString(text):
+		return Classification{Task: TaskCode, By: "heuristic"}
+	case len(text) < 200 && len(req.Messages) <= 6 && len(req.Tools) == 0:
+		return Classification{Task: TaskQuick, By: "heuristic"}
+	default:
+		return Classification{Task: TaskGeneral, By: "heuristic"}
+	}
+}
+
+// Classify with a small local model, keeping heuristics for vision, private and long-context
+// requests; a helper that is not local is skipped so request text never leaves the machine
+type helperClassifier struct {
+	core     *Core
+	provider string
+	model    string
+}
+
+func (h *helperClassifier) Classify(ctx context.Context, req *AIRequest) Classification {
+	fallback := HeuristicClassifier{}.Classify(ctx, req)
+	if req.Task != "" {
+		return fallback
+	}
+	if _, ok := hardTask(req); ok {
+		return fallback
+	}
+
+	h.core.mu.RLock()
+	helper, ok := h.core.providers[h.provider]
+	local := h.core.isLocal(h.provider)
+	h.core.mu.RUnlock()
+	if !ok || !local {
+		return fallback
+	}
+	provider := newScheduledProvider(h.core.withModel(helper, h.model), h.core.scheduler, PriorityInteractive)
+
+	text := lastUserMessage(req.Messages)
+	if len(text) > 2000 {
+		text = text[:2000]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, classifyTimeout)
+	defer cancel()
+
+	response, err := provider.Chat(ctx, []providers.Message{{Role: "user", Content: text}}, &providers.ChatOptions{
+		SystemPrompt: "Classify the user's message as exactly one word: quick (short chat), code (programming), private (secrets or personal data) or general. Reply with the word only.",
+		Temperature:  0,
+		MaxTokens:    5,
+	})
+	if err != nil {
+		return fallback
+	}
+
+	switch task := TaskClass(strings.ToLower(strings.Trim(strings.TrimSpace(response.Content), ".\"'`"))); task {
+	case TaskQuick, TaskCode, TaskPrivate, TaskGeneral:
+		return Classification{Task: task, By: "helper:" + h.provider}
+	default:
+		return fallback
+	}
+}
+
+// Tasks decided by what the request carries rather than what it says
+func hardTask(req *AIRequest) (TaskClass, bool) {
+	switch {
+	case req.EnableVision && len(req.Images) > 0:
+		return TaskVision, true
+	case isPrivate(req):
+		return TaskPrivate, true
+	case estimatePromptTokens(req) > longContextTokens:
+		return TaskLongContext, true
+	}
+	return "", false
+}
+
+// Report whether the caller marked a request private, rather than its text looking private
+func explicitlyPrivate(req *AIRequest) bool {
+	private, _ := req.Context["private"].(bool)
+	return private || req.Task == TaskPrivate
+}
+
+// Report whether a request should stay on local providers
+func isPrivate(req *AIRequest) bool {
+	if explicitlyPrivate(req) || req.routedTask == TaskPrivate {
+		return true
+	}
+	for _, msg := range req.Messages {
+		if msg.Role == "user" && privatePattern.MatchString(msg.Content) {
+			return true
+		}
+	}
+	return false
+}
+
+// Estimate prompt tokens the way the ledger does (~4 characters each)
+func estimatePromptTokens(req *AIRequest) int {
+	characters := len(req.SystemPrompt)
+	for _, msg := range req.Messages {
+		characters += len(msg.Content)
+	}
+	return characters / 4
+}
+
+func lastUserMessage(messages []providers.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// Route by task: config rules first, then capabilities, declared tasks, the active provider, cost and latency
+type TaskPolicy struct {
+	classifier TaskClassifier
+	rules      []taskRule
+}
+
+type taskRule struct {
+	config.RoutingRule
+	pattern *regexp.Regexp
+}
+
+// Create a task policy; rules are checked in order
+func NewTaskPolicy(classifier TaskClassifier, rules []config.RoutingRule) (*TaskPolicy, error) {
+	if classifier == nil {
+		classifier = HeuristicClassifier{}
+	}
+
+	policy := &TaskPolicy{classifier: classifier}
+	for i, rule := range rules {
+		pattern, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("routing rule %d: %w", i+1, err)
+		}
+		policy.rules = append(policy.rules, taskRule{RoutingRule: rule, pattern: pattern})
+	}
+	return policy, nil
+}
+
+func (p *TaskPolicy) Route(ctx context.Context, req *AIRequest, candidates []RouteCandidate) (*RoutingDecision, error) {
+	classification := p.classifier.Classify(ctx, req)
+	decision := &RoutingDecision{
+		Task:       classification.Task,
+		Classifier: classification.By,
+		Rejected:   make(map[string]string),
+	}
+
+	// Requests that only look private may leave the machine when nothing local is registered
+	stayLocal := decision.Task == TaskPrivate && explicitlyPrivate(req)
+	for _, candidate := range candidates {
+		stayLocal = stayLocal || decision.Task == TaskPrivate && candidate.Local
+	}
+
+	// Capabilities the request cannot do without
+	needed := estimatePromptTokens(req) + req.MaxTokens
+	var capable []RouteCandidate
+	for _, candidate := range candidates {
+		switch {
+		case req.EnableVision && len(req.Images) > 0 && !candidate.Vision:
+			decision.Rejected[candidate.Name] = "no vision support"
+		case len(req.Tools) > 0 && !candidate.Tools:
+			decision.Rejected[candidate.Name] = "no tool calling"
+		case stayLocal && !candidate.Local:
+			decision.Rejected[candidate.Name] = "not local"
+		default:
+			capable = append(capable, candidate)
+		}
+	}
+
+	// Health and context size are preferences: with nothing better, a tight or failing provider still gets a try
+	eligible := preferred(capable, decision, func(candidate RouteCandidate) string {
+		if !candidate.Healthy {
+			return "unhealthy"
+		}
+		return ""
+	})
+	eligible = preferred(eligible, decision, func(candidate RouteCandidate) string {
+		if candidate.ContextWindow > 0 && needed > candidate.ContextWindow {
+			return fmt.Sprintf("needs ~%d tokens, window is %d", needed, candidate.ContextWindow)
+		}
+		return ""
+	})
+	if len(eligible) == 0 {
+		return decision, fmt.Errorf("no provider can serve this %s request (%s)", decision.Task, formatRejected(decision.Rejected))
+	}
+
+	// Config rules pick a provider outright when it can serve the request
+	text := lastUserMessage(req.Messages)
+	for i, rule := range p.rules {
+		if (rule.Task != "" && TaskClass(rule.Task) != decision.Task) || !rule.pattern.MatchString(text) {
+			continue
+		}
+		for _, candidate := range eligible {
+			if candidate.Name == rule.Provider {
+				decision.Provider = candidate.Name
+				decision.Model = rule.Model
+				decision.Rule = i + 1
+				decision.Reason = fmt.Sprintf("rule %d sends %s requests to %s", i+1, decision.Task, rule.Provider)
+				return decision, nil
+			}
+		}
+		if _, rejected := decision.Rejected[rule.Provider]; !rejected {
+			decision.Rejected[rule.Provider] = fmt.Sprintf("rule %d matched but the provider is not registered", i+1)
+		}
+	}
+
+	quick := decision.Task == TaskQuick || decision.Task == TaskPrivate
+	sort.SliceStable(eligible, func(i, j int) bool {
+		a, b := eligible[i], eligible[j]
+		if declaresTask(a, decision.Task) != declaresTask(b, decision.Task) {
+			return declaresTask(a, decision.Task)
+		}
+		if a.Active != b.Active {
+			return a.Active
+		}
+		if quick && a.Latency != b.Latency {
+			return latencyBefore(a, b)
+		}
+		if a.Priced != b.Priced {
+			return a.Priced
+		}
+		if cost(a) != cost(b) {
+			return cost(a) < cost(b)
+		}
+		if a.Latency != b.Latency {
+			return latencyBefore(a, b)
+		}
+		return a.Position < b.Position
+	})
+
+	chosen := eligible[0]
+	decision.Provider = chosen.Name
+	decision.Model = chosen.Model
+	switch {
+	case declaresTask(chosen, decision.Task):
+		decision.Reason = fmt.Sprintf("configured for %s requests", decision.Task)
+	case decision.Task == TaskPrivate && chosen.Local:
+		decision.Reason = "local provider keeps private data on this machine"
+	case chosen.Active:
+		decision.Reason = "active provider"
+	case quick && chosen.Latency > 0:
+		decision.Reason = fmt.Sprintf("fastest suitable provider (%s avg)", chosen.Latency.Round(time.Millisecond))
+	case chosen.Priced:
+		decision.Reason = fmt.Sprintf("cheapest suitable provider ($%.2f/M tokens)", cost(chosen))
+	default:
+		decision.Reason = "first suitable fallback"
+	}
+	if decision.Task == TaskPrivate && !chosen.Local {
+		decision.Reason += "; no local provider is registered"
+	}
+	return decision, nil
+}
+
+// Keep candidates passing a check, recording why the others were passed over; if none pass, keep them all
+func preferred(candidates []RouteCandidate, decision *RoutingDecision, check func(RouteCandidate) string) []RouteCandidate {
+	var passed []RouteCandidate
+	reasons := make(map[string]string)
+	for _, candidate := range candidates {
+		if reason := check(candidate); reason != "" {
+			reasons[candidate.Name] = reason
+			continue
+		}
+		passed = append(passed, candidate)
+	}
+	if len(passed) == 0 {
+		return candidates
+	}
+	for name, reason := range reasons {
+		decision.Rejected[name] = reason
+	}
+	return passed
+}
+
+func declaresTask(candidate RouteCandidate, task TaskClass) bool {
+	for _, declared := range candidate.Tasks {
+		if declared == task {
+			return true
+		}
+	}
+	return false
+}
+
+// Order by measured latency; providers not measured yet go last
+func latencyBefore(a, b RouteCandidate) bool {
+	if a.Latency == 0 || b.Latency == 0 {
+		return b.Latency == 0 && a.Latency != 0
+	}
+	return a.Latency < b.Latency
+}
+
+// Blend input and output prices, weighting output like a typical chat reply
+func cost(candidate RouteCandidate) float64 {
+	return candidate.Price.Input + candidate.Price.Output/4
+}
+
+func formatRejected(rejected map[string]string) string {
+	if len(rejected) == 0 {
+		return "no providers registered"
+	}
+	names := make([]string, 0, len(rejected))
+	for name := range rejected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reasons := make([]string, len(names))
+	for i, name := range names {
+		reasons[i] = name + ": " + rejected[name]
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// Look up a model's context window by longest name prefix
+func contextWindow(model string) int {
+	best := ""
+	for prefix := range defaultContextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return 0
+	}
+	return defaultContextWindows[best]
+}
+
+// Build the routing policy described by the config
+func newRoutingPolicy(core *Core, routing config.RoutingConfig) RoutingPolicy {
+	var classifier TaskClassifier = HeuristicClassifier{}
+	if provider, model := routing.ClassifierProvider(); provider != "" {
+		classifier = &helperClassifier{core: core, provider: provider, model: model}
+	}
+
+	policy, err := NewTaskPolicy(classifier, routing.Rules)
+	if err != nil {
+		// Loaded configs are validated, so only hand-built ones get here; route without their rules
+		policy, _ = NewTaskPolicy(classifier, nil)
+	}
+	return policy
+}
+
+// Describe the active provider, the default and fallbacks for the routing policy
+func (c *Core) routeCandidates() []RouteCandidate {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var candidates []RouteCandidate
+	listed := make(map[string]bool)
+	for _, name := range append([]string{c.activeModel, c.config.DefaultProvider}, c.config.FallbackProviders...) {
+		provider, exists := c.providers[name]
+		if !exists || listed[name] {
+			continue
+		}
+		listed[name] = true
+
+		candidate := RouteCandidate{
+			Name:     name,
+			Model:    providerModel(provider),
+			Active:   name == c.activeModel,
+			Position: len(candidates),
+			Local:    c.isLocal(name),
+			Vision:   supportsVision(provider),
+			Healthy:  c.health.Healthy(name),
+			Latency:  c.health.Health(name).Latency,
+		}
+		if toolProvider, ok := provider.(providers.ToolProvider); ok {
+			candidate.Tools = toolProvider.SupportsTools()
+		}
+
+		settings, configured := c.settings.Provider(name)
+		for _, task := range settings.Tasks {
+			candidate.Tasks = append(candidate.Tasks, TaskClass(task))
+		}
+		switch {
+		case settings.ContextWindow > 0:
+			candidate.ContextWindow = settings.ContextWindow
+		case candidate.Local && configured:
+			candidate.ContextWindow = localContextWindow
+		default:
+			candidate.ContextWindow = contextWindow(candidate.Model)
+		}
+
+		if candidate.Local {
+			candidate.Priced = true
+		} else if c.ledger != nil {
+			candidate.Price, candidate.Priced = c.ledger.Price(name, candidate.Model)
+		}
+
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// Report whether a provider runs on this machine (caller holds the lock)
+func (c *Core) isLocal(name string) bool {
+	if settings, exists := c.settings.Provider(name); exists {
+		return settings.Local()
+	}
+	return name == "ollama" || name == "fake"
+}
+
+// Report whether any registered provider runs on this machine (caller holds the lock)
+func (c *Core) hasLocalProvider() bool {
+	for name := range c.providers {
+		if c.isLocal(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decide which provider should serve a request, without sending it
+func (c *Core) Route(ctx context.Context, req *AIRequest) (*RoutingDecision, error) {
+	c.mu.RLock()
+	policy := c.router
+	c.mu.RUnlock()
+
+	if policy == nil {
+		return nil, fmt.Errorf("no routing policy set")
+	}
+	return policy.Route(ctx, req, c.routeCandidates())
+}
+
+// Replace the routing policy; nil keeps the active provider with plain fallbacks
+func (c *Core) SetRoutingPolicy(policy RoutingPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.router = policy
+}
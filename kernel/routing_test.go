@@ -0,0 +1,61 @@
+package kernel
+
+import (
+	"context"
+	"testing"
+
+	"nero/config"
+	"nero/providers"
+)
+
+// Answer every chat with the provider's name
+type echoProvider struct{ name string }
+
+func (p *echoProvider) Name() string      { return p.name }
+func (p *echoProvider) IsAvailable() bool { return true }
+
+func (p *echoProvider) Chat(ctx context.Context, messages []providers.Message, options *providers.ChatOptions) (*providers.Response, error) {
+	return &providers.Response{Content: p.name}, nil
+}
+
+func TestFallbackHonoursRoutedPrivacy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	settings := config.Default()
+	settings.Providers = []config.ProviderConfig{
+		{Name: "local", Type: "compatible", BaseURL: "http://127.0.0.1:1234/v1", Model: "qwen2.5"},
+		{Name: "cloud", Type: "compatible", BaseURL: "https://api.example.com/v1", Model: "big"},
+		{Name: "backup", Type: "compatible", BaseURL: "http://localhost:8080/v1", Model: "small"},
+	}
+	settings.Routing.DefaultProvider = "local"
+	settings.Routing.Fallbacks = []string{"cloud", "backup"}
+
+	core := NewCoreFromConfig(settings)
+	for _, provider := range settings.Providers {
+		core.RegisterProvider(provider.Name, &echoProvider{name: provider.Name})
+	}
+
+	// Nothing in the text looks private, but the classifier said it was
+	req := &AIRequest{Messages: []providers.Message{{Role: "user", Content: "what should I cook tonight"}}}
+	routed := routedRequest(req, &RoutingDecision{Task: TaskPrivate})
+
+	tried := map[string]bool{"local": true}
+	if got := providerNames(core.fallbackCandidates(req, tried)); len(got) != 2 {
+		t.Errorf("unclassified fallbacks = %v, want cloud and backup", got)
+	}
+	if got := providerNames(core.fallbackCandidates(routed, tried)); len(got) != 1 || got[0] != "backup" {
+		t.Errorf("private fallbacks = %v, want only the local backup", got)
+	}
+	if req.routedTask != "" {
+		t.Error("routing changed the caller's request")
+	}
+}
+
+// List the providers by name
+func providerNames(list []providers.AIProvider) []string {
+	var result []string
+	for _, provider := range list {
+		result = append(result, provider.Name())
+	}
+	return result
+}
@@ -0,0 +1,295 @@
+package kernel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"nero/providers"
+)
+
+// Order requests competing for a provider; lower values go first
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota // The user is waiting on it
+	PriorityBackground                  // Helper calls like kaomoji and memory extraction
+	PriorityBatch                       // Bulk work that can wait indefinitely
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBackground:
+		return "background"
+	case PriorityBatch:
+		return "batch"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+// Report requests running and waiting for one provider
+type QueueStats struct {
+	Provider string
+	Running  int
+	Limit    int
+	Queued   map[Priority]int
+}
+
+// Total number of waiting requests
+func (q QueueStats) Depth() int {
+	depth := 0
+	for _, count := range q.Queued {
+		depth += count
+	}
+	return depth
+}
+
+// Bound concurrent calls per provider, admitting queued requests by priority
+type Scheduler struct {
+	limit   int
+	running map[string]int
+	waiting map[string][]*ticket
+	next    uint64
+	mu      sync.Mutex
+}
+
+type ticket struct {
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+	granted  bool
+}
+
+// Create a scheduler allowing limit concurrent calls per provider
+func NewScheduler(limit int) *Scheduler {
+	if limit < 1 {
+		limit = 1
+	}
+	return &Scheduler{
+		limit:   limit,
+		running: make(map[string]int),
+		waiting: make(map[string][]*ticket),
+	}
+}
+
+// Wait for a slot on a provider; the returned release must be called when the call ends
+func (s *Scheduler) Acquire(ctx context.Context, provider string, priority Priority, deadline time.Time) (func(), error) {
+	s.mu.Lock()
+	if s.running[provider] < s.limit && len(s.waiting[provider]) == 0 {
+		s.running[provider]++
+		s.mu.Unlock()
+		return s.releaser(provider), nil
+	}
+
+	s.next++
+	t := &ticket{priority: priority, seq: s.next, ready: make(chan struct{})}
+	s.enqueue(provider, t)
+	s.mu.Unlock()
+
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-t.ready:
+		return s.releaser(provider), nil
+	case <-ctx.Done():
+		return nil, s.abandon(provider, t, ctx.Err())
+	case <-expired:
+		ahead := s.Stats(provider).Depth() - 1
+		return nil, s.abandon(provider, t, fmt.Errorf("%s request waited too long for %s (%d others queued)", priority, provider, ahead))
+	}
+}
+
+// Report running and queued requests for a provider
+func (s *Scheduler) Stats(provider string) QueueStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := QueueStats{
+		Provider: provider,
+		Running:  s.running[provider],
+		Limit:    s.limit,
+		Queued:   make(map[Priority]int),
+	}
+	for _, t := range s.waiting[provider] {
+		stats.Queued[t.priority]++
+	}
+	return stats
+}
+
+// Change the per-provider limit, admitting waiting requests if it grew
+func (s *Scheduler) SetLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit < 1 {
+		limit = 1
+	}
+	s.limit = limit
+	for provider := range s.waiting {
+		s.dispatch(provider)
+	}
+}
+
+// Insert a ticket keeping the queue ordered by priority, then arrival (caller holds the lock)
+func (s *Scheduler) enqueue(provider string, t *ticket) {
+	queue := append(s.waiting[provider], t)
+	sort.SliceStable(queue, func(i, j int) bool {
+		if queue[i].priority != queue[j].priority {
+			return queue[i].priority < queue[j].priority
+		}
+		return queue[i].seq < queue[j].seq
+	})
+	s.waiting[provider] = queue
+}
+
+// Admit waiting requests while there is room (caller holds the lock)
+func (s *Scheduler) dispatch(provider string) {
+	for s.running[provider] < s.limit && len(s.waiting[provider]) > 0 {
+		t := s.waiting[provider][0]
+		s.waiting[provider] = s.waiting[provider][1:]
+		s.running[provider]++
+		t.granted = true
+		close(t.ready)
+	}
+	if len(s.waiting[provider]) == 0 {
+		delete(s.waiting, provider)
+	}
+}
+
+// Leave the queue; a slot granted while giving up is passed on
+func (s *Scheduler) abandon(provider string, t *ticket, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.granted {
+		s.running[provider]--
+		s.dispatch(provider)
+		return err
+	}
+
+	queue := s.waiting[provider]
+	for i, waiting := range queue {
+		if waiting == t {
+			s.waiting[provider] = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(s.waiting[provider]) == 0 {
+		delete(s.waiting, provider)
+	}
+	return err
+}
+
+func (s *Scheduler) releaser(provider string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.running[provider]--
+			if s.running[provider] <= 0 {
+				delete(s.running, provider)
+			}
+			s.dispatch(provider)
+		})
+	}
+}
+
+// Route a provider's calls through the scheduler at a fixed priority
+type scheduledProvider struct {
+	providers.AIProvider
+	scheduler *Scheduler
+	priority  Priority
+}
+
+// A scheduled provider whose inner provider streams; kept separate so only streaming providers pass as one
+type scheduledStreamer struct {
+	*scheduledProvider
+}
+
+// Wrap a provider in the scheduler, keeping its streaming capability only when it has one
+func newScheduledProvider(provider providers.AIProvider, scheduler *Scheduler, priority Priority) providers.AIProvider {
+	scheduled := &scheduledProvider{AIProvider: provider, scheduler: scheduler, priority: priority}
+	if _, ok := provider.(providers.StreamingProvider); ok {
+		return &scheduledStreamer{scheduled}
+	}
+	return scheduled
+}
+
+func (p *scheduledProvider) Chat(ctx context.Context, messages []providers.Message, options *providers.ChatOptions) (*providers.Response, error) {
+	release, err := p.scheduler.Acquire(ctx, p.Name(), p.priority, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return p.AIProvider.Chat(ctx, messages, options)
+}
+
+func (p *scheduledStreamer) ChatStream(ctx context.Context, messages []providers.Message, options *providers.ChatOptions, callback providers.StreamCallback) error {
+	release, err := p.scheduler.Acquire(ctx, p.Name(), p.priority, time.Time{})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return p.AIProvider.(providers.StreamingProvider).ChatStream(ctx, messages, options, callback)
+}
+
+func (p *scheduledProvider) SupportsVision() bool {
+	return supportsVision(p.AIProvider)
+}
+
+func (p *scheduledProvider) ChatWithVision(ctx context.Context, messages []providers.VisionMessage, options *providers.ChatOptions) (*providers.Response, error) {
+	vision, ok := p.AIProvider.(providers.VisionProvider)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support vision", p.Name())
+	}
+
+	release, err := p.scheduler.Acquire(ctx, p.Name(), p.priority, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return vision.ChatWithVision(ctx, messages, options)
+}
+
+func (p *scheduledProvider) SupportsReasoning() bool {
+	reasoning, ok := p.AIProvider.(providers.ReasoningProvider)
+	return ok && reasoning.SupportsReasoning()
+}
+
+func (p *scheduledProvider) ChatWithReasoning(ctx context.Context, messages []providers.Message, options *providers.ChatOptions) (*providers.ReasoningResponse, error) {
+	reasoning, ok := p.AIProvider.(providers.ReasoningProvider)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support reasoning", p.Name())
+	}
+
+	release, err := p.scheduler.Acquire(ctx, p.Name(), p.priority, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return reasoning.ChatWithReasoning(ctx, messages, options)
+}
+
+func (p *scheduledProvider) Model() string {
+	return providerModel(p.AIProvider)
+}
+
+func (p *scheduledProvider) SupportsTools() bool {
+	tools, ok := p.AIProvider.(providers.ToolProvider)
+	return ok && tools.SupportsTools()
+}
@@ -0,0 +1,89 @@
+package kernel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nero/providers"
+)
+
+// Stream from a provider that holds its slot until released
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) Name() string      { return "slow" }
+func (p *blockingProvider) IsAvailable() bool { return true }
+
+func (p *blockingProvider) Chat(ctx context.Context, messages []providers.Message, options *providers.ChatOptions) (*providers.Response, error) {
+	return &providers.Response{Content: "ok"}, nil
+}
+
+func (p *blockingProvider) ChatStream(ctx context.Context, messages []providers.Message, options *providers.ChatOptions, callback providers.StreamCallback) error {
+	p.started <- struct{}{}
+	<-p.release
+	callback(providers.StreamChunk{Content: "ok", Done: true})
+	return nil
+}
+
+func TestScheduledProviderStreamsInTurn(t *testing.T) {
+	scheduler := NewScheduler(1)
+	inner := &blockingProvider{started: make(chan struct{}, 2), release: make(chan struct{})}
+	interactive := newScheduledProvider(inner, scheduler, PriorityInteractive).(providers.StreamingProvider)
+	background := newScheduledProvider(inner, scheduler, PriorityBackground).(providers.StreamingProvider)
+
+	done := make(chan error, 2)
+	go func() {
+		done <- interactive.ChatStream(context.Background(), nil, nil, func(providers.StreamChunk) {})
+	}()
+	<-inner.started
+
+	// The background stream queues while the interactive one holds the only slot
+	go func() {
+		done <- background.ChatStream(context.Background(), nil, nil, func(providers.StreamChunk) {})
+	}()
+	for deadline := time.Now().Add(time.Second); scheduler.Stats("slow").Queued[PriorityBackground] != 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("background stream did not queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats := scheduler.Stats("slow"); stats.Running != 1 {
+		t.Errorf("running = %d, want 1", stats.Running)
+	}
+
+	inner.release <- struct{}{}
+	<-inner.started
+	inner.release <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("ChatStream: %v", err)
+		}
+	}
+}
+
+func TestScheduledProviderKeepsCapabilities(t *testing.T) {
+	scheduler := NewScheduler(1)
+
+	// A chat-only provider must not pass for a streaming, vision or reasoning one
+	chatOnly := newScheduledProvider(&echoProvider{name: "plain"}, scheduler, PriorityBackground)
+	if _, ok := chatOnly.(providers.StreamingProvider); ok {
+		t.Error("chat-only provider claims to stream")
+	}
+	if supportsVision(chatOnly) {
+		t.Error("chat-only provider claims vision support")
+	}
+	if reasoning, ok := chatOnly.(providers.ReasoningProvider); ok && reasoning.SupportsReasoning() {
+		t.Error("chat-only provider claims reasoning support")
+	}
+
+	vision := newScheduledProvider(providers.NewCompatibleProvider(providers.CompatibleConfig{Name: "llava", BaseURL: "http://127.0.0.1:1", Model: "llava", Vision: true}), scheduler, PriorityBackground)
+	if _, ok := vision.(providers.StreamingProvider); !ok {
+		t.Error("streaming provider lost streaming")
+	}
+	if !supportsVision(vision) {
+		t.Error("vision provider lost vision support")
+	}
+}
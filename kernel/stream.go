@@ -0,0 +1,279 @@
+package kernel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Handle real-time response streaming
+type StreamManager struct {
+	activeStreams map[string]*StreamContext
+	lastID        atomic.Uint64 // Numbers streams so IDs stay unique when two start in the same instant
+	mu            sync.RWMutex
+}
+
+// Track individual streaming context
+type StreamContext struct {
+	ID        string
+	Provider  string // Updated when a fallback provider takes over
+	StartTime time.Time
+	Channel   chan StreamChunk // Written by the producer, which alone closes it when the stream ends
+	Cancel    context.CancelFunc
+
+	ctx         context.Context
+	chunks      []StreamChunk // Everything published so far, replayed to late subscribers
+	characters  int
+	tokens      int // Reported completion tokens, once the provider sends usage
+	subscribers int
+	closed      bool
+	cond        *sync.Cond
+	mu          sync.Mutex
+}
+
+// How long a finished stream can still be subscribed to, so consumers that arrive late see the whole answer
+const streamLinger = time.Minute
+
+// Report a stream that is still producing
+type StreamInfo struct {
+	ID          string
+	Provider    string
+	StartTime   time.Time
+	Elapsed     time.Duration
+	Tokens      int  // Completion tokens so far
+	Estimated   bool // Tokens guessed from text length (~4 characters each)
+	Subscribers int
+}
+
+// Create stream manager
+func NewStreamManager() *StreamManager {
+	return &StreamManager{
+		activeStreams: make(map[string]*StreamContext),
+	}
+}
+
+// Register a stream whose producer runs under the returned context and closes Channel when done
+func (sm *StreamManager) start(ctx context.Context, provider string) (*StreamContext, context.Context) {
+	streamCtx, cancel := context.WithCancel(ctx)
+
+	stream := &StreamContext{
+		ID:        fmt.Sprintf("stream_%d_%d", time.Now().UnixNano(), sm.lastID.Add(1)),
+		Provider:  provider,
+		StartTime: time.Now(),
+		Channel:   make(chan StreamChunk, 100),
+		Cancel:    cancel,
+		ctx:       streamCtx,
+	}
+	stream.cond = sync.NewCond(&stream.mu)
+
+	sm.addStream(stream.ID, stream)
+	go sm.pump(stream)
+
+	return stream, streamCtx
+}
+
+// Copy the producer's chunks to subscribers until the producer closes the channel
+func (sm *StreamManager) pump(stream *StreamContext) {
+	done := false
+	for chunk := range stream.Channel {
+		stream.publish(chunk)
+		done = done || chunk.Done
+	}
+
+	// A cancelled producer stops without a final chunk; tell subscribers why
+	if !done && stream.ctx.Err() != nil {
+		stream.publish(StreamChunk{Type: "cancelled", Done: true, Error: stream.ctx.Err()})
+	}
+
+	stream.finish()
+	stream.Cancel()
+	time.AfterFunc(streamLinger, func() {
+		sm.removeStream(stream.ID)
+	})
+}
+
+// Add new stream
+func (sm *StreamManager) addStream(id string, ctx *StreamContext) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.activeStreams[id] = ctx
+}
+
+// Remove stream
+func (sm *StreamManager) removeStream(id string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.activeStreams, id)
+}
+
+// Get a stream by ID
+func (sm *StreamManager) getStream(id string) (*StreamContext, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	stream, exists := sm.activeStreams[id]
+	return stream, exists
+}
+
+// Stop a stream; its subscribers receive a final cancelled chunk
+func (sm *StreamManager) cancel(id string) bool {
+	stream, exists := sm.getStream(id)
+	if !exists || stream.finished() {
+		return false
+	}
+	stream.Cancel()
+	return true
+}
+
+// Describe every active stream, oldest first
+func (sm *StreamManager) active() []StreamInfo {
+	sm.mu.RLock()
+	streams := make([]*StreamContext, 0, len(sm.activeStreams))
+	for _, stream := range sm.activeStreams {
+		if !stream.finished() {
+			streams = append(streams, stream)
+		}
+	}
+	sm.mu.RUnlock()
+
+	infos := make([]StreamInfo, len(streams))
+	for i, stream := range streams {
+		infos[i] = stream.info()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartTime.Before(infos[j].StartTime)
+	})
+	return infos
+}
+
+// Record a chunk and wake subscribers waiting for it
+func (s *StreamContext) publish(chunk StreamChunk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chunks = append(s.chunks, chunk)
+	if chunk.Switch != nil {
+		s.Provider = chunk.Switch.To
+	}
+	s.characters += len(chunk.Content)
+	if chunk.Usage != nil && chunk.Usage.CompletionTokens > 0 {
+		s.tokens = chunk.Usage.CompletionTokens
+	}
+	s.cond.Broadcast()
+}
+
+// Record the provider now producing the stream
+func (s *StreamContext) setProvider(provider string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Provider = provider
+}
+
+// Mark the stream finished so subscribers close once they have read everything
+func (s *StreamContext) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.cond.Broadcast()
+}
+
+func (s *StreamContext) finished() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Return a channel receiving the stream from its first chunk; it closes when the stream ends or ctx is done
+func (s *StreamContext) subscribe(ctx context.Context) <-chan StreamChunk {
+	out := make(chan StreamChunk, 100)
+
+	s.mu.Lock()
+	s.subscribers++
+	s.mu.Unlock()
+
+	// Wake the subscriber if it is waiting for chunks when ctx ends
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cond.Broadcast()
+	})
+
+	go func() {
+		defer close(out)
+		defer stop()
+		defer func() {
+			s.mu.Lock()
+			s.subscribers--
+			s.mu.Unlock()
+		}()
+
+		for next := 0; ; next++ {
+			s.mu.Lock()
+			for next >= len(s.chunks) && !s.closed && ctx.Err() == nil {
+				s.cond.Wait()
+			}
+			if next >= len(s.chunks) || ctx.Err() != nil {
+				s.mu.Unlock()
+				return
+			}
+			chunk := s.chunks[next]
+			s.mu.Unlock()
+
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func (s *StreamContext) info() StreamInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := StreamInfo{
+		ID:          s.ID,
+		Provider:    s.Provider,
+		StartTime:   s.StartTime,
+		Elapsed:     time.Since(s.StartTime),
+		Tokens:      s.tokens,
+		Subscribers: s.subscribers,
+	}
+	if info.Tokens == 0 {
+		info.Tokens = s.characters / 4
+		info.Estimated = true
+	}
+	return info
+}
+
+// Stop a stream early, reporting whether it was still running; subscribers get a final chunk carrying context.Canceled
+func (c *Core) CancelStream(streamID string) bool {
+	return c.streamManager.cancel(streamID)
+}
+
+// Tee a stream to another consumer, replaying what was already sent; the channel closes when the stream ends
+// or ctx is done, so a consumer that stops reading early must cancel ctx to release the subscription
+func (c *Core) Subscribe(ctx context.Context, streamID string) (<-chan StreamChunk, bool) {
+	stream, exists := c.streamManager.getStream(streamID)
+	if !exists {
+		return nil, false
+	}
+	return stream.subscribe(ctx), true
+}
+
+// Get streaming channel for real-time updates; read it until it closes, or use Subscribe to stop early
+func (c *Core) GetStreamChannel(streamID string) (<-chan StreamChunk, bool) {
+	return c.Subscribe(context.Background(), streamID)
+}
+
+// List streams still producing, oldest first
+func (c *Core) ActiveStreams() []StreamInfo {
+	return c.streamManager.active()
+}
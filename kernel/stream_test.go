@@ -0,0 +1,71 @@
+package kernel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nero/config"
+	"nero/providers"
+)
+
+func TestStreamIDsAreUnique(t *testing.T) {
+	manager := NewStreamManager()
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		stream, _ := manager.start(context.Background(), "test")
+		close(stream.Channel)
+		if seen[stream.ID] {
+			t.Fatalf("stream %d reused ID %s", i, stream.ID)
+		}
+		seen[stream.ID] = true
+	}
+}
+
+// Answer every chat with nothing at all
+type silentProvider struct{}
+
+func (silentProvider) Name() string      { return "silent" }
+func (silentProvider) IsAvailable() bool { return true }
+
+func (silentProvider) Chat(ctx context.Context, messages []providers.Message, options *providers.ChatOptions) (*providers.Response, error) {
+	return &providers.Response{}, nil
+}
+
+func TestEmptySimulatedStreamFinishes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	settings := config.Default()
+	settings.Providers = nil
+	settings.Routing.DefaultProvider = ""
+	settings.Routing.Fallbacks = nil
+	core := NewCoreFromConfig(settings)
+	core.RegisterProvider("silent", silentProvider{})
+
+	response, err := core.ProcessRequest(context.Background(), &AIRequest{
+		Messages:     []providers.Message{{Role: "user", Content: "hi"}},
+		EnableStream: true,
+	})
+	if err != nil {
+		t.Fatalf("ProcessRequest: %v", err)
+	}
+	chunks, ok := core.Subscribe(context.Background(), response.StreamID)
+	if !ok {
+		t.Fatal("stream not found")
+	}
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case chunk, open := <-chunks:
+			if !open {
+				t.Fatal("stream closed without a final chunk")
+			}
+			if chunk.Done {
+				return
+			}
+		case <-timeout:
+			t.Fatal("no final chunk")
+		}
+	}
+}
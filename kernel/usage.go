@@ -0,0 +1,252 @@
+package kernel
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"nero/providers"
+)
+
+// Price a model in USD per million tokens
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// List prices for hosted models, matched by longest model-name prefix
+var defaultPrices = map[string]Price{
+	"gpt-4o":                  {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":             {Input: 0.15, Output: 0.60},
+	"gpt-4.1":                 {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":            {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":            {Input: 0.10, Output: 0.40},
+	"gpt-5":                   {Input: 1.25, Output: 10.00},
+	"gpt-5-mini":              {Input: 0.25, Output: 2.00},
+	"o3":                      {Input: 2.00, Output: 8.00},
+	"o4-mini":                 {Input: 1.10, Output: 4.40},
+	"text-embedding-3-small":  {Input: 0.02},
+	"text-embedding-3-large":  {Input: 0.13},
+	"claude-opus-4":           {Input: 15.00, Output: 75.00},
+	"claude-sonnet-4":         {Input: 3.00, Output: 15.00},
+	"claude-3-7-sonnet":       {Input: 3.00, Output: 15.00},
+	"claude-3-5-haiku":        {Input: 0.80, Output: 4.00},
+	"claude-haiku-4":          {Input: 1.00, Output: 5.00},
+	"gemini-2.5-pro":          {Input: 1.25, Output: 10.00},
+	"gemini-2.5-flash":        {Input: 0.30, Output: 2.50},
+	"gemini-2.0-flash":        {Input: 0.10, Output: 0.40},
+	"gemini-1.5-flash":        {Input: 0.075, Output: 0.30},
+	"llama-3.3-70b-versatile": {Input: 0.59, Output: 0.79},
+	"llama-3.1-8b-instant":    {Input: 0.05, Output: 0.08},
+}
+
+// Record the token usage of a single request
+type UsageRecord struct {
+	Time             time.Time `json:"time"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`                // Estimated USD
+	Estimated        bool      `json:"estimated,omitempty"` // Tokens guessed from text length
+}
+
+// Aggregate a group of usage records
+type UsageTotals struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	Estimated        bool // At least one record had guessed token counts
+}
+
+// Break usage down by day and by model
+type UsageSummary struct {
+	Since   time.Time
+	Total   UsageTotals
+	ByDay   map[string]*UsageTotals // Keyed by 2006-01-02
+	ByModel map[string]*UsageTotals // Keyed by provider/model
+}
+
+// Persist usage records as JSON lines and price them
+type Ledger struct {
+	path   string
+	prices map[string]Price
+	mu     sync.Mutex
+}
+
+// Return the default ledger location under ~/.nero
+func DefaultLedgerPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".nero", "usage.jsonl")
+}
+
+// Create a ledger, merging price overrides from prices.json next to it
+func NewLedger(path string) *Ledger {
+	ledger := &Ledger{
+		path:   path,
+		prices: make(map[string]Price),
+	}
+
+	for model, price := range defaultPrices {
+		ledger.prices[model] = price
+	}
+
+	// Let teams keep prices current without a rebuild
+	if data, err := os.ReadFile(filepath.Join(filepath.Dir(path), "prices.json")); err == nil {
+		var overrides map[string]Price
+		if json.Unmarshal(data, &overrides) == nil {
+			for model, price := range overrides {
+				ledger.prices[model] = price
+			}
+		}
+	}
+
+	return ledger
+}
+
+// Look up the price for a model; local providers are free
+func (l *Ledger) Price(provider, model string) (Price, bool) {
+	if provider == "ollama" {
+		return Price{}, true
+	}
+
+	best := ""
+	for prefix := range l.prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return l.prices[best], true
+}
+
+// Price and append a record to the ledger
+func (l *Ledger) Record(record UsageRecord) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	price, _ := l.Price(record.Provider, record.Model)
+	record.Cost = (float64(record.PromptTokens)*price.Input + float64(record.CompletionTokens)*price.Output) / 1_000_000
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Read all records at or after since
+func (l *Ledger) Records(since time.Time) ([]UsageRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // Nothing recorded yet
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue // Skip lines damaged by an interrupted write
+		}
+		if !record.Time.Before(since) {
+			records = append(records, record)
+		}
+	}
+
+	return records, scanner.Err()
+}
+
+// Summarize usage at or after since
+func (l *Ledger) Summarize(since time.Time) (*UsageSummary, error) {
+	records, err := l.Records(since)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &UsageSummary{
+		Since:   since,
+		ByDay:   make(map[string]*UsageTotals),
+		ByModel: make(map[string]*UsageTotals),
+	}
+
+	for _, record := range records {
+		summary.Total.add(record)
+
+		day := record.Time.Local().Format("2006-01-02")
+		if summary.ByDay[day] == nil {
+			summary.ByDay[day] = &UsageTotals{}
+		}
+		summary.ByDay[day].add(record)
+
+		model := record.Provider + "/" + record.Model
+		if summary.ByModel[model] == nil {
+			summary.ByModel[model] = &UsageTotals{}
+		}
+		summary.ByModel[model].add(record)
+	}
+
+	return summary, nil
+}
+
+// Add a record to the totals
+func (t *UsageTotals) add(record UsageRecord) {
+	t.Requests++
+	t.PromptTokens += record.PromptTokens
+	t.CompletionTokens += record.CompletionTokens
+	t.Cost += record.Cost
+	t.Estimated = t.Estimated || record.Estimated
+}
+
+// Build a usage record, estimating tokens (~4 characters each) from the prompt and completion text when the provider reported none
+func NewUsageRecord(provider, model string, usage *providers.Usage, prompt, completion string) UsageRecord {
+	record := UsageRecord{
+		Time:     time.Now(),
+		Provider: provider,
+		Model:    model,
+	}
+
+	if usage != nil && usage.TotalTokens+usage.PromptTokens+usage.CompletionTokens > 0 {
+		record.PromptTokens = usage.PromptTokens
+		record.CompletionTokens = usage.CompletionTokens
+		if record.PromptTokens+record.CompletionTokens == 0 {
+			record.CompletionTokens = usage.TotalTokens
+		}
+		return record
+	}
+
+	record.PromptTokens = len(prompt) / 4
+	record.CompletionTokens = len(completion) / 4
+	record.Estimated = true
+	return record
+}
@@ -2,22 +2,60 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"nero/behavioral"
 	"nero/capabilities"
 	"nero/capabilities/ai"
 	"nero/cli"
+	"nero/config"
 	extensions "nero/extensions/nero"
 	"nero/kernel"
+	"nero/providers"
 )
 
 func main() {
+	// Handle subcommands that run without the REPL
+	if len(os.Args) > 1 && os.Args[1] == "auth" {
+		if err := cli.RunAuth(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	configPath := flag.String("config", "", "config file to use instead of ~/.nero/config.json")
+	fakeScript := flag.String("fake", "", "answer from a fake provider script instead of real providers")
+	flag.Parse()
+	if *configPath != "" {
+		os.Setenv(config.PathEnv, *configPath)
+	}
+	if *fakeScript != "" {
+		os.Setenv(providers.FakeScriptEnv, *fakeScript)
+	}
+
+	// Load settings before anything reads them
+	settings, err := config.Load("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid config:\n%v\n", err)
+		os.Exit(1)
+	}
+	cli.ApplyTheme(settings.Theme)
+
+	if path := os.Getenv(providers.FakeScriptEnv); path != "" {
+		if _, err := providers.NewFakeProviderFromFile(path); err != nil {
+			log.Fatal("Failed to load fake provider script:", err)
+		}
+	}
+
 	// Initialize runtime
 	runtime := kernel.NewRuntime()
 	if err := runtime.Start(); err != nil {
@@ -25,8 +63,21 @@ func main() {
 	}
 	defer runtime.Stop()
 
-	// Initialize AI providers
-	aiRouter := ai.LoadProviders()
+	// Initialize AI providers, recording usage in the ledger /usage reads
+	ledger := kernel.NewLedger(kernel.DefaultLedgerPath())
+	aiRouter := ai.LoadProviders(func(provider, model string, usage *providers.Usage, prompt, completion string) {
+		ledger.Record(kernel.NewUsageRecord(provider, model, usage, prompt, completion))
+	})
+
+	// Helper model calls wait behind the answer the user is waiting on
+	scheduler := kernel.NewScheduler(settings.Routing.MaxConcurrentCalls)
+	aiRouter.SetScheduler(func(ctx context.Context, provider string, background bool) (func(), error) {
+		priority := kernel.PriorityInteractive
+		if background {
+			priority = kernel.PriorityBackground
+		}
+		return scheduler.Acquire(ctx, provider, priority, time.Time{})
+	})
 
 	// Initialize capabilities (unused for now)
 	_ = capabilities.NewLoader("extensions")
@@ -39,7 +90,7 @@ func main() {
 	// }
 
 	// Initialize @nero extension
-	neroExt := extensions.NewNeroExtension()
+	neroExt := extensions.NewNeroExtension(settings)
 	if err := neroExt.Initialize(); err != nil {
 		log.Fatal("Failed to initialize @nero extension:", err)
 	}
@@ -100,7 +151,7 @@ func main() {
 		}
 
 		// Handle special commands
-		if handleSpecialCommand(input, repl, neroExt) {
+		if handleSpecialCommand(input, repl, neroExt, ledger) {
 			continue
 		}
 
@@ -111,13 +162,14 @@ func main() {
 	}
 }
 
-func handleSpecialCommand(input string, repl *cli.REPL, neroExt *extensions.NeroExtension) bool {
+func handleSpecialCommand(input string, repl *cli.REPL, neroExt *extensions.NeroExtension, ledger *kernel.Ledger) bool {
 	switch input {
 	case "/help":
 		repl.PrintMessage(`Nero Commands:
   /help       - Show this help
   /clear      - Clear screen  
   /status     - Show system status
+  /usage [days] - Show token usage and estimated cost
   /quit, /exit - Exit Nero
   
   @nero <cmd> - Execute @nero extension commands
@@ -143,6 +195,24 @@ func handleSpecialCommand(input string, repl *cli.REPL, neroExt *extensions.Nero
 		return true
 	}
 
+	// Handle /usage [days]
+	if args := strings.Fields(input); len(args) > 0 && args[0] == "/usage" {
+		days, err := cli.ParseUsageDays(args[1:])
+		if err != nil {
+			repl.PrintError(err)
+			return true
+		}
+
+		report, err := cli.FormatUsage(ledger, days)
+		if err != nil {
+			repl.PrintError(err)
+			return true
+		}
+
+		repl.PrintMessage(report)
+		return true
+	}
+
 	// Handle @nero commands
 	if len(input) > 5 && input[:5] == "@nero" {
 		args := parseCommand(input[5:])
@@ -6,10 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
+
+	"nero/providers/credentials"
+	"nero/providers/sse"
 )
 
 // Define the interface for AI model providers
@@ -39,13 +44,38 @@ type ReasoningProvider interface {
 	ChatWithReasoning(ctx context.Context, messages []Message, options *ChatOptions) (*ReasoningResponse, error)
 }
 
+// Report which model a provider is bound to
+type ModelProvider interface {
+	AIProvider
+	Model() string
+}
+
+// Let configuration point a provider at another server
+type EndpointProvider interface {
+	SetBaseURL(baseURL string)
+}
+
+// Let configuration supply a provider's API key
+type KeyProvider interface {
+	SetAPIKey(key string)
+}
+
+// Define tool-calling capability interface
+type ToolProvider interface {
+	AIProvider
+	SupportsTools() bool
+}
+
 // Callback for streaming responses
 type StreamCallback func(chunk StreamChunk)
 
 // Represent a chat message
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"` // "system", "user", "assistant" or "tool"
+	Content    string     `json:"content"`
+	Name       string     `json:"name,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
 
 // Represent a vision-enabled message
@@ -69,19 +99,28 @@ type ImageURL struct {
 
 // Configure chat behavior
 type ChatOptions struct {
-	Temperature    float64 `json:"temperature,omitempty"`
-	MaxTokens      int     `json:"max_tokens,omitempty"`
-	SystemPrompt   string  `json:"system_prompt,omitempty"`
-	Stream         bool    `json:"stream,omitempty"`
-	EnableVision   bool    `json:"enable_vision,omitempty"`
-	EnableThoughts bool    `json:"enable_thoughts,omitempty"`
-	TopP           float64 `json:"top_p,omitempty"`
+	Temperature    float64  `json:"temperature,omitempty"`
+	MaxTokens      int      `json:"max_tokens,omitempty"`
+	SystemPrompt   string   `json:"system_prompt,omitempty"`
+	Stream         bool     `json:"stream,omitempty"`
+	EnableVision   bool     `json:"enable_vision,omitempty"`
+	EnableThoughts bool     `json:"enable_thoughts,omitempty"`
+	TopP           float64  `json:"top_p,omitempty"`
+	Tools          []Tool   `json:"tools,omitempty"`
+	ToolChoice     string   `json:"tool_choice,omitempty"` // "auto", "none", "required"
+	Stop           []string `json:"stop,omitempty"`
+	Seed           *int     `json:"seed,omitempty"`
+	KeepAlive      string   `json:"keep_alive,omitempty"` // Ollama only, e.g. "10m" or "-1"
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 }
 
 // Represent an AI response
 type Response struct {
 	Content    string
+	ToolCalls  []ToolCall
 	TokensUsed int
+	Usage      *Usage // Prompt/completion split, when the provider reports it
 	Model      string
 	Metadata   map[string]interface{}
 }
@@ -93,11 +132,20 @@ type ReasoningResponse struct {
 	ThoughtsTime time.Duration
 }
 
+// Report token usage for a single request
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
 // Represent a streaming chunk
 type StreamChunk struct {
 	Content   string
 	Type      string // "text", "reasoning", "vision"
 	IsThought bool
+	ToolCalls []ToolCall
+	Usage     *Usage // Set on the final chunk when the provider reports it
 	Delta     bool
 	Done      bool
 	Error     error
@@ -106,16 +154,41 @@ type StreamChunk struct {
 
 // Implement local Ollama integration with streaming
 type OllamaProvider struct {
-	baseURL string
-	model   string
+	baseURL   string
+	model     string
+	transport *Transport
 }
 
 // Create a new Ollama provider
 func NewOllamaProvider(model string) *OllamaProvider {
 	return &OllamaProvider{
-		baseURL: "http://localhost:11434",
-		model:   model,
+		baseURL:   OllamaHost(),
+		model:     model,
+		transport: NewTransport("ollama", LocalTimeout),
+	}
+}
+
+// Resolve the Ollama server address, honouring OLLAMA_HOST like the ollama CLI does
+func OllamaHost() string {
+	host := strings.TrimSpace(os.Getenv("OLLAMA_HOST"))
+	if host == "" {
+		return "http://localhost:11434"
+	}
+
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return strings.TrimRight(host, "/")
+	}
+
+	if u.Port() == "" && u.Scheme == "http" {
+		u.Host = net.JoinHostPort(u.Hostname(), "11434")
 	}
+
+	return strings.TrimRight(u.String(), "/")
 }
 
 func (o *OllamaProvider) Name() string {
@@ -123,7 +196,15 @@ func (o *OllamaProvider) Name() string {
 }
 
 func (o *OllamaProvider) IsAvailable() bool {
-	resp, err := http.Get(o.baseURL + "/api/tags")
+	ctx, cancel := context.WithTimeout(context.Background(), ProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", o.baseURL+"/api/tags", nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return false
 	}
@@ -131,175 +212,233 @@ func (o *OllamaProvider) IsAvailable() bool {
 	return resp.StatusCode == 200
 }
 
+func (o *OllamaProvider) Model() string {
+	return o.model
+}
+
+func (o *OllamaProvider) SetBaseURL(baseURL string) {
+	o.baseURL = strings.TrimRight(baseURL, "/")
+}
+
+func (o *OllamaProvider) SetTimeout(timeout time.Duration) {
+	o.transport.SetTimeout(timeout)
+}
+
+func (o *OllamaProvider) SupportsTools() bool {
+	return true
+}
+
+func (o *OllamaProvider) SupportsReasoning() bool {
+	return isReasoningModel(o.model)
+}
+
+// Stream the request and collect the model's <think> output separately from the answer
+func (o *OllamaProvider) ChatWithReasoning(ctx context.Context, messages []Message, options *ChatOptions) (*ReasoningResponse, error) {
+	response, err := collectReasoning(func(callback StreamCallback) error {
+		return o.ChatStream(ctx, messages, options, callback)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response.Model = o.model
+	response.Metadata = map[string]interface{}{
+		"provider": "ollama",
+	}
+	return response, nil
+}
+
+// Detect multimodal models by name, since /api/chat has no capability flag
+func (o *OllamaProvider) SupportsVision() bool {
+	model := strings.ToLower(o.model)
+	for _, family := range []string{"llava", "vision", "bakllava", "moondream", "gemma3", "qwen2.5vl", "minicpm-v", "llama4"} {
+		if strings.Contains(model, family) {
+			return true
+		}
+	}
+	return false
+}
+
 func (o *OllamaProvider) Chat(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
 	if options != nil && options.Stream {
-		// Use streaming but collect full response
-		var fullResponse strings.Builder
-		err := o.ChatStream(ctx, messages, options, func(chunk StreamChunk) {
-			if !chunk.Done && chunk.Error == nil {
-				fullResponse.WriteString(chunk.Content)
-			}
+		// Use streaming but collect full response, keeping thoughts out of the answer
+		response, err := collectReasoning(func(callback StreamCallback) error {
+			return o.ChatStream(ctx, messages, options, callback)
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return &Response{
-			Content: fullResponse.String(),
-			Model:   o.model,
-			Metadata: map[string]interface{}{
-				"provider": "ollama",
-			},
-		}, nil
+		response.Model = o.model
+		response.Metadata = map[string]interface{}{
+			"provider": "ollama",
+		}
+		return &response.Response, nil
 	}
 
 	return o.chatNonStreaming(ctx, messages, options)
 }
 
-func (o *OllamaProvider) chatNonStreaming(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
-	// Build prompt from messages
-	var prompt strings.Builder
+// Build an /api/chat request body with native roles and full options
+func (o *OllamaProvider) chatRequest(messages []Message, options *ChatOptions, stream bool) map[string]interface{} {
+	reqData := map[string]interface{}{
+		"model":    o.model,
+		"messages": ollamaMessages(messages, options),
+		"stream":   stream,
+	}
 
-	// Add system prompt if provided
-	if options != nil && options.SystemPrompt != "" {
-		prompt.WriteString("System: " + options.SystemPrompt + "\n\n")
+	if options == nil {
+		return reqData
 	}
 
-	// Add conversation history
-	for _, msg := range messages {
-		switch msg.Role {
-		case "user":
-			prompt.WriteString("Human: " + msg.Content + "\n\n")
-		case "assistant":
-			prompt.WriteString("Assistant: " + msg.Content + "\n\n")
-		}
+	modelOptions := map[string]interface{}{}
+	if options.Temperature > 0 {
+		modelOptions["temperature"] = options.Temperature
+	}
+	if options.TopP > 0 {
+		modelOptions["top_p"] = options.TopP
+	}
+	if options.MaxTokens > 0 {
+		modelOptions["num_predict"] = options.MaxTokens
+	}
+	if len(options.Stop) > 0 {
+		modelOptions["stop"] = options.Stop
+	}
+	if options.Seed != nil {
+		modelOptions["seed"] = *options.Seed
+	}
+	if len(modelOptions) > 0 {
+		reqData["options"] = modelOptions
 	}
 
-	prompt.WriteString("Assistant: ")
+	if options.KeepAlive != "" {
+		reqData["keep_alive"] = options.KeepAlive
+	}
 
-	// Prepare request
-	reqData := map[string]interface{}{
-		"model":  o.model,
-		"prompt": prompt.String(),
-		"stream": false,
+	if options.ResponseFormat != nil {
+		reqData["format"] = options.ResponseFormat.Ollama()
 	}
 
-	if options != nil {
-		if options.Temperature > 0 {
-			reqData["options"] = map[string]interface{}{
-				"temperature": options.Temperature,
-			}
-		}
+	if len(options.Tools) > 0 {
+		reqData["tools"] = openAITools(options.Tools)
 	}
 
+	return reqData
+}
+
+// Send an /api/chat request and return the raw response
+func (o *OllamaProvider) post(ctx context.Context, reqData map[string]interface{}) (*http.Response, error) {
 	jsonData, err := json.Marshal(reqData)
 	if err != nil {
 		return nil, err
 	}
 
-	// Make request to Ollama API
-	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/generate", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/chat", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := o.transport.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama error: %s", string(body))
-	}
+	return resp, nil
+}
 
-	// Parse Ollama response
-	var result struct {
-		Response string `json:"response"`
-		Done     bool   `json:"done"`
-	}
+// Represent a single /api/chat response object
+type ollamaChatResponse struct {
+	Message struct {
+		Content   string           `json:"content"`
+		Thinking  string           `json:"thinking"` // Set when the server separates thoughts itself
+		ToolCalls []ollamaToolCall `json:"tool_calls"`
+	} `json:"message"`
+	Done            bool `json:"done"`
+	PromptEvalCount int  `json:"prompt_eval_count"` // Reported on the final object
+	EvalCount       int  `json:"eval_count"`
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+// Convert Ollama eval counts to token usage
+func (r *ollamaChatResponse) usage() *Usage {
+	return &Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
 	}
-
-	return &Response{
-		Content: strings.TrimSpace(result.Response),
-		Model:   o.model,
-		Metadata: map[string]interface{}{
-			"provider": "ollama",
-		},
-	}, nil
 }
 
-// Implement streaming for Ollama
-func (o *OllamaProvider) ChatStream(ctx context.Context, messages []Message, options *ChatOptions, callback StreamCallback) error {
-	// Build prompt from messages
-	var prompt strings.Builder
+func (o *OllamaProvider) chatNonStreaming(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
+	return o.complete(ctx, o.chatRequest(messages, options, false))
+}
 
-	// Add system prompt if provided
-	if options != nil && options.SystemPrompt != "" {
-		prompt.WriteString("System: " + options.SystemPrompt + "\n\n")
+// Implement vision by attaching base64 images to each message
+func (o *OllamaProvider) ChatWithVision(ctx context.Context, messages []VisionMessage, options *ChatOptions) (*Response, error) {
+	apiMessages, err := ollamaVisionMessages(ctx, messages, options)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add conversation history
-	for _, msg := range messages {
-		switch msg.Role {
-		case "user":
-			prompt.WriteString("Human: " + msg.Content + "\n\n")
-		case "assistant":
-			prompt.WriteString("Assistant: " + msg.Content + "\n\n")
-		}
-	}
+	reqData := o.chatRequest(nil, options, false)
+	reqData["messages"] = apiMessages
 
-	prompt.WriteString("Assistant: ")
+	response, err := o.complete(ctx, reqData)
+	if err != nil {
+		return nil, err
+	}
+	response.Metadata["vision"] = true
+	return response, nil
+}
 
-	// Prepare streaming request
-	reqData := map[string]interface{}{
-		"model":  o.model,
-		"prompt": prompt.String(),
-		"stream": true,
+// Send a non-streaming /api/chat request
+func (o *OllamaProvider) complete(ctx context.Context, reqData map[string]interface{}) (*Response, error) {
+	resp, err := o.post(ctx, reqData)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	if options != nil {
-		if options.Temperature > 0 {
-			reqData["options"] = map[string]interface{}{
-				"temperature": options.Temperature,
-			}
-		}
+	var result ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
 	}
 
-	jsonData, err := json.Marshal(reqData)
-	if err != nil {
-		return err
+	// Keep inline thoughts out of the answer
+	thoughts, content := splitThoughts(o.model, result.Message.Content)
+	if result.Message.Thinking != "" {
+		thoughts = result.Message.Thinking
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/generate", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
+	usage := result.usage()
+	response := &Response{
+		Content:    content,
+		ToolCalls:  parseOllamaToolCalls(result.Message.ToolCalls),
+		TokensUsed: usage.TotalTokens,
+		Usage:      usage,
+		Model:      o.model,
+		Metadata: map[string]interface{}{
+			"provider": "ollama",
+		},
 	}
-	req.Header.Set("Content-Type", "application/json")
+	if thoughts != "" {
+		response.Metadata["thoughts"] = thoughts
+	}
+	return response, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// Implement streaming for Ollama
+func (o *OllamaProvider) ChatStream(ctx context.Context, messages []Message, options *ChatOptions, callback StreamCallback) error {
+	resp, err := o.post(ctx, o.chatRequest(messages, options, true))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("ollama error: %s", string(body))
-	}
-
-	// Process streaming response
+	// Process streaming response, splitting inline <think> spans into thought chunks
+	parser := newThinkParser(o.model)
 	decoder := json.NewDecoder(resp.Body)
 	for {
-		var result struct {
-			Response string `json:"response"`
-			Done     bool   `json:"done"`
-		}
+		var result ollamaChatResponse
 
 		if err := decoder.Decode(&result); err != nil {
 			if err == io.EOF {
@@ -309,13 +448,37 @@ func (o *OllamaProvider) ChatStream(ctx context.Context, messages []Message, opt
 			return err
 		}
 
-		// Send chunk to callback
-		callback(StreamChunk{
-			Content: result.Response,
-			Type:    "text",
-			Delta:   true,
-			Done:    result.Done,
-		})
+		if result.Message.Thinking != "" {
+			callback(StreamChunk{
+				Content:   result.Message.Thinking,
+				Type:      "reasoning",
+				IsThought: true,
+				Delta:     true,
+			})
+		}
+
+		chunks := parser.Feed(result.Message.Content)
+		if result.Done {
+			chunks = append(chunks, parser.Flush()...)
+		}
+		for _, chunk := range chunks {
+			callback(chunk)
+		}
+
+		// Send tool calls and completion in a closing chunk
+		toolCalls := parseOllamaToolCalls(result.Message.ToolCalls)
+		if len(toolCalls) > 0 || result.Done {
+			chunk := StreamChunk{
+				Type:      "text",
+				ToolCalls: toolCalls,
+				Delta:     true,
+				Done:      result.Done,
+			}
+			if result.Done {
+				chunk.Usage = result.usage()
+			}
+			callback(chunk)
+		}
 
 		if result.Done {
 			break
@@ -332,262 +495,239 @@ func (o *OllamaProvider) ChatStream(ctx context.Context, messages []Message, opt
 	return nil
 }
 
-// Implement OpenAI/compatible API with vision and streaming
-type OpenAIProvider struct {
-	apiKey  string
-	baseURL string
-	model   string
+// Implement Google Gemini provider
+type GeminiProvider struct {
+	apiKey    string
+	baseURL   string
+	model     string
+	transport *Transport
 }
 
-// Create a new OpenAI provider
-func NewOpenAIProvider(model string) *OpenAIProvider {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		// Try reading from file
-		if data, err := os.ReadFile("openai.key"); err == nil {
-			apiKey = strings.TrimSpace(string(data))
-		}
+// Create a new Gemini provider
+func NewGeminiProvider(model string) *GeminiProvider {
+	apiKey := credentials.Lookup("gemini")
+
+	baseURL := os.Getenv("GEMINI_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://generativelanguage.googleapis.com/v1beta"
 	}
 
-	return &OpenAIProvider{
-		apiKey:  apiKey,
-		baseURL: "https://api.openai.com/v1",
-		model:   model,
+	return &GeminiProvider{
+		apiKey:    apiKey,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		model:     model,
+		transport: NewTransport("gemini", DefaultTimeout),
 	}
 }
 
-func (o *OpenAIProvider) Name() string {
-	return "openai"
+func (g *GeminiProvider) Name() string {
+	return "gemini"
 }
 
-func (o *OpenAIProvider) IsAvailable() bool {
-	return o.apiKey != ""
+func (g *GeminiProvider) IsAvailable() bool {
+	return g.apiKey != ""
 }
 
-func (o *OpenAIProvider) SupportsVision() bool {
-	return strings.Contains(o.model, "vision") || strings.Contains(o.model, "4o")
+func (g *GeminiProvider) SupportsVision() bool {
+	// Every Gemini chat model is multimodal; only the embedding models are text-only
+	return !strings.Contains(g.model, "embedding")
+}
+
+func (g *GeminiProvider) Model() string {
+	return g.model
+}
+
+func (g *GeminiProvider) SetBaseURL(baseURL string) {
+	g.baseURL = strings.TrimRight(baseURL, "/")
 }
 
-func (o *OpenAIProvider) Chat(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
-	if !o.IsAvailable() {
-		return nil, fmt.Errorf("OpenAI API key not available")
+func (g *GeminiProvider) SetAPIKey(key string) {
+	g.apiKey = key
+}
+
+func (g *GeminiProvider) SetTimeout(timeout time.Duration) {
+	g.transport.SetTimeout(timeout)
+}
+
+func (g *GeminiProvider) SupportsTools() bool {
+	return true
+}
+
+func (g *GeminiProvider) Chat(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
+	if !g.IsAvailable() {
+		return nil, fmt.Errorf("Gemini API key not available")
 	}
 
 	if options != nil && options.Stream {
-		// Use streaming but collect full response
-		var fullResponse strings.Builder
-		err := o.ChatStream(ctx, messages, options, func(chunk StreamChunk) {
-			if !chunk.Done && chunk.Error == nil {
-				fullResponse.WriteString(chunk.Content)
-			}
+		// Use streaming but collect full response, keeping thoughts out of the answer
+		response, err := collectReasoning(func(callback StreamCallback) error {
+			return g.ChatStream(ctx, messages, options, callback)
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return &Response{
-			Content: fullResponse.String(),
-			Model:   o.model,
-			Metadata: map[string]interface{}{
-				"provider": "openai",
-			},
-		}, nil
+		response.Model = g.model
+		response.Metadata = map[string]interface{}{
+			"provider": "gemini",
+		}
+		return &response.Response, nil
 	}
 
-	return o.chatNonStreaming(ctx, messages, options)
+	return g.generate(ctx, geminiRequest(messages, options))
 }
 
-func (o *OpenAIProvider) chatNonStreaming(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
-	// Prepare messages for OpenAI format
-	var apiMessages []map[string]string
-
-	if options != nil && options.SystemPrompt != "" {
-		apiMessages = append(apiMessages, map[string]string{
-			"role":    "system",
-			"content": options.SystemPrompt,
-		})
+// Implement vision by sending images as inline data parts
+func (g *GeminiProvider) ChatWithVision(ctx context.Context, messages []VisionMessage, options *ChatOptions) (*Response, error) {
+	if !g.IsAvailable() {
+		return nil, fmt.Errorf("Gemini API key not available")
 	}
 
-	for _, msg := range messages {
-		apiMessages = append(apiMessages, map[string]string{
-			"role":    msg.Role,
-			"content": msg.Content,
-		})
+	contents, err := geminiVisionContents(ctx, messages)
+	if err != nil {
+		return nil, err
 	}
 
-	reqData := map[string]interface{}{
-		"model":    o.model,
-		"messages": apiMessages,
-	}
+	reqData := geminiRequest(nil, options)
+	reqData["contents"] = contents
 
-	if options != nil {
-		if options.Temperature > 0 {
-			reqData["temperature"] = options.Temperature
-		}
-		if options.MaxTokens > 0 {
-			reqData["max_tokens"] = options.MaxTokens
-		}
+	response, err := g.generate(ctx, reqData)
+	if err != nil {
+		return nil, err
 	}
+	response.Metadata["vision"] = true
+	return response, nil
+}
 
+// Send a generateContent request and collect the non-thought parts
+func (g *GeminiProvider) generate(ctx context.Context, reqData map[string]interface{}) (*Response, error) {
 	jsonData, err := json.Marshal(reqData)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", g.baseURL, g.model, g.apiKey)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+o.apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := g.transport.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("openai error: %s", string(body))
-	}
-
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Usage struct {
-			TotalTokens int `json:"total_tokens"`
-		} `json:"usage"`
-	}
+	var result geminiResponse
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
-	if len(result.Choices) == 0 {
-		return nil, fmt.Errorf("no response from OpenAI")
+	if len(result.Candidates) == 0 || len(result.Candidates[0].Content.Parts) == 0 {
+		return nil, fmt.Errorf("no response from Gemini")
 	}
 
-	return &Response{
-		Content:    result.Choices[0].Message.Content,
-		TokensUsed: result.Usage.TotalTokens,
-		Model:      o.model,
-		Metadata: map[string]interface{}{
-			"provider": "openai",
-		},
-	}, nil
-}
-
-// Implement streaming for OpenAI
-func (o *OpenAIProvider) ChatStream(ctx context.Context, messages []Message, options *ChatOptions, callback StreamCallback) error {
-	// Prepare messages for OpenAI format
-	var apiMessages []map[string]string
-
-	if options != nil && options.SystemPrompt != "" {
-		apiMessages = append(apiMessages, map[string]string{
-			"role":    "system",
-			"content": options.SystemPrompt,
-		})
+	var content strings.Builder
+	var toolCalls []ToolCall
+	for _, part := range result.Candidates[0].Content.Parts {
+		if part.Thought {
+			continue
+		}
+		content.WriteString(part.Text)
+		if part.FunctionCall != nil {
+			toolCalls = append(toolCalls, part.FunctionCall.toolCall(len(toolCalls)))
+		}
 	}
 
-	for _, msg := range messages {
-		apiMessages = append(apiMessages, map[string]string{
-			"role":    msg.Role,
-			"content": msg.Content,
-		})
+	response := &Response{
+		Content:   content.String(),
+		ToolCalls: toolCalls,
+		Model:     g.model,
+		Metadata: map[string]interface{}{
+			"provider": "gemini",
+		},
 	}
-
-	reqData := map[string]interface{}{
-		"model":    o.model,
-		"messages": apiMessages,
-		"stream":   true,
+	if result.UsageMetadata != nil {
+		response.TokensUsed = result.UsageMetadata.TotalTokenCount
+		response.Usage = result.UsageMetadata.usage()
 	}
+	return response, nil
+}
 
-	if options != nil {
-		if options.Temperature > 0 {
-			reqData["temperature"] = options.Temperature
-		}
-		if options.MaxTokens > 0 {
-			reqData["max_tokens"] = options.MaxTokens
-		}
+// Implement streaming for Gemini via streamGenerateContent server-sent events
+func (g *GeminiProvider) ChatStream(ctx context.Context, messages []Message, options *ChatOptions, callback StreamCallback) error {
+	if !g.IsAvailable() {
+		return fmt.Errorf("Gemini API key not available")
 	}
 
-	jsonData, err := json.Marshal(reqData)
+	jsonData, err := json.Marshal(geminiRequest(messages, options))
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse&key=%s", g.baseURL, g.model, g.apiKey)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+o.apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := g.transport.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("openai error: %s", string(body))
-	}
-
-	// Process streaming response
-	reader := resp.Body
-	buffer := make([]byte, 4096)
+	// Usage metadata is cumulative, so the last value seen is the final count
+	var usage *Usage
+	toolIndex := 0
 
+	decoder := sse.NewDecoder(resp.Body)
 	for {
-		n, err := reader.Read(buffer)
+		event, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
 			callback(StreamChunk{Error: err, Done: true})
 			return err
 		}
 
-		// Parse SSE data
-		data := string(buffer[:n])
-		lines := strings.Split(data, "\n")
-
-		for _, line := range lines {
-			if strings.HasPrefix(line, "data: ") {
-				jsonData := strings.TrimPrefix(line, "data: ")
-				if jsonData == "[DONE]" {
-					callback(StreamChunk{Done: true})
-					return nil
-				}
-
-				var result struct {
-					Choices []struct {
-						Delta struct {
-							Content string `json:"content"`
-						} `json:"delta"`
-					} `json:"choices"`
-				}
-
-				if err := json.Unmarshal([]byte(jsonData), &result); err != nil {
-					continue // Skip malformed JSON
-				}
+		var result geminiResponse
+		if jsonErr := json.Unmarshal([]byte(event.Data), &result); jsonErr == nil {
+			if result.UsageMetadata != nil {
+				usage = result.UsageMetadata.usage()
+			}
 
-				if len(result.Choices) > 0 {
-					content := result.Choices[0].Delta.Content
-					if content != "" {
+			for _, candidate := range result.Candidates {
+				for _, part := range candidate.Content.Parts {
+					if part.FunctionCall != nil {
 						callback(StreamChunk{
-							Content: content,
-							Type:    "text",
-							Delta:   true,
-							Done:    false,
+							Type:      "text",
+							ToolCalls: []ToolCall{part.FunctionCall.toolCall(toolIndex)},
 						})
+						toolIndex++
+						continue
+					}
+
+					if part.Text == "" {
+						continue
+					}
+
+					chunkType := "text"
+					if part.Thought {
+						chunkType = "reasoning"
 					}
+					callback(StreamChunk{
+						Content:   part.Text,
+						Type:      chunkType,
+						IsThought: part.Thought,
+						Delta:     true,
+					})
 				}
 			}
 		}
@@ -600,67 +740,64 @@ func (o *OpenAIProvider) ChatStream(ctx context.Context, messages []Message, opt
 		}
 	}
 
+	callback(StreamChunk{Done: true, Usage: usage})
 	return nil
 }
 
-// Implement Google Gemini provider
-type GeminiProvider struct {
-	apiKey string
-	model  string
+// Represent a generateContent response (also each streamed event)
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []geminiPart `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata *geminiUsage `json:"usageMetadata"`
 }
 
-// Create a new Gemini provider
-func NewGeminiProvider(model string) *GeminiProvider {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		// Try reading from file
-		if data, err := os.ReadFile("gemini.key"); err == nil {
-			apiKey = strings.TrimSpace(string(data))
-		}
-	}
+// Represent Gemini token accounting
+type geminiUsage struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
 
-	return &GeminiProvider{
-		apiKey: apiKey,
-		model:  model,
+// Convert to the provider-neutral usage, counting thoughts as completion
+func (u *geminiUsage) usage() *Usage {
+	return &Usage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		TotalTokens:      u.TotalTokenCount,
 	}
 }
 
-func (g *GeminiProvider) Name() string {
-	return "gemini"
+// Represent a content part returned by Gemini
+type geminiPart struct {
+	Text         string              `json:"text"`
+	Thought      bool                `json:"thought"`
+	FunctionCall *geminiFunctionCall `json:"functionCall"`
 }
 
-func (g *GeminiProvider) IsAvailable() bool {
-	return g.apiKey != ""
+// Represent a function call part returned by Gemini
+type geminiFunctionCall struct {
+	ID   string                 `json:"id"`
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
 }
 
-func (g *GeminiProvider) SupportsVision() bool {
-	return strings.Contains(g.model, "vision") || strings.Contains(g.model, "2.0")
-}
-
-func (g *GeminiProvider) Chat(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
-	if !g.IsAvailable() {
-		return nil, fmt.Errorf("Gemini API key not available")
-	}
-
-	// Convert messages to Gemini format
-	var contents []map[string]interface{}
-
-	for _, msg := range messages {
-		role := "user"
-		if msg.Role == "assistant" {
-			role = "model"
-		}
-
-		contents = append(contents, map[string]interface{}{
-			"role": role,
-			"parts": []map[string]string{
-				{"text": msg.Content},
-			},
-		})
+// Convert to a ToolCall, synthesizing an ID when Gemini omits one
+func (f *geminiFunctionCall) toolCall(index int) ToolCall {
+	id := f.ID
+	if id == "" {
+		id = fmt.Sprintf("call_%d", index)
 	}
+	return ToolCall{ID: id, Name: f.Name, Arguments: f.Args}
+}
 
+// Build a generateContent request body
+func geminiRequest(messages []Message, options *ChatOptions) map[string]interface{} {
 	reqData := map[string]interface{}{
-		"contents": contents,
+		"contents": geminiContents(messages),
 	}
 
 	if options != nil {
@@ -671,6 +808,26 @@ func (g *GeminiProvider) Chat(ctx context.Context, messages []Message, options *
 		if options.MaxTokens > 0 {
 			config["maxOutputTokens"] = options.MaxTokens
 		}
+		if options.TopP > 0 {
+			config["topP"] = options.TopP
+		}
+		if len(options.Stop) > 0 {
+			config["stopSequences"] = options.Stop
+		}
+		if options.Seed != nil {
+			config["seed"] = *options.Seed
+		}
+		if options.EnableThoughts {
+			config["thinkingConfig"] = map[string]interface{}{
+				"includeThoughts": true,
+			}
+		}
+		if options.ResponseFormat != nil {
+			config["responseMimeType"] = "application/json"
+			if options.ResponseFormat.Schema != nil {
+				config["responseSchema"] = geminiSchema(options.ResponseFormat.Schema)
+			}
+		}
 		if len(config) > 0 {
 			reqData["generationConfig"] = config
 		}
@@ -682,178 +839,53 @@ func (g *GeminiProvider) Chat(ctx context.Context, messages []Message, options *
 				},
 			}
 		}
-	}
 
-	jsonData, err := json.Marshal(reqData)
-	if err != nil {
-		return nil, err
-	}
-
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", g.model, g.apiKey)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+		if len(options.Tools) > 0 {
+			reqData["tools"] = geminiTools(options.Tools)
+			reqData["toolConfig"] = map[string]interface{}{
+				"functionCallingConfig": map[string]string{
+					"mode": geminiToolMode(options.ToolChoice),
+				},
+			}
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("gemini error: %s", string(body))
-	}
+	return reqData
+}
 
-	var result struct {
-		Candidates []struct {
-			Content struct {
-				Parts []struct {
-					Text string `json:"text"`
-				} `json:"parts"`
-			} `json:"content"`
-		} `json:"candidates"`
-		UsageMetadata struct {
-			TotalTokenCount int `json:"totalTokenCount"`
-		} `json:"usageMetadata"`
-	}
+// Implement OpenAI on top of the compatible provider, adding vision detection
+type OpenAIProvider struct {
+	*CompatibleProvider
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
+// Create a new OpenAI provider
+func NewOpenAIProvider(model string) *OpenAIProvider {
+	apiKey := credentials.Lookup("openai")
 
-	if len(result.Candidates) == 0 || len(result.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no response from Gemini")
+	return &OpenAIProvider{
+		CompatibleProvider: newHostedProvider("openai", "https://api.openai.com/v1", apiKey, model),
 	}
+}
 
-	return &Response{
-		Content:    result.Candidates[0].Content.Parts[0].Text,
-		TokensUsed: result.UsageMetadata.TotalTokenCount,
-		Model:      g.model,
-		Metadata: map[string]interface{}{
-			"provider": "gemini",
-		},
-	}, nil
+func (o *OpenAIProvider) SupportsVision() bool {
+	return strings.Contains(o.model, "vision") || strings.Contains(o.model, "4o") ||
+		strings.Contains(o.model, "gpt-4.1") || strings.Contains(o.model, "gpt-5")
 }
 
 // Implement Groq provider (uses OpenAI-compatible API)
 type GroqProvider struct {
-	apiKey string
-	model  string
+	*CompatibleProvider
 }
 
 // Create a new Groq provider
 func NewGroqProvider(model string) *GroqProvider {
-	apiKey := os.Getenv("GROQ_API_KEY")
-	if apiKey == "" {
-		// Try reading from file
-		if data, err := os.ReadFile("groq.key"); err == nil {
-			apiKey = strings.TrimSpace(string(data))
-		}
-	}
+	apiKey := credentials.Lookup("groq")
 
 	return &GroqProvider{
-		apiKey: apiKey,
-		model:  model,
+		CompatibleProvider: newHostedProvider("groq", "https://api.groq.com/openai/v1", apiKey, model),
 	}
 }
 
-func (g *GroqProvider) Name() string {
-	return "groq"
-}
-
-func (g *GroqProvider) IsAvailable() bool {
-	return g.apiKey != ""
-}
-
-func (g *GroqProvider) Chat(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
-	if !g.IsAvailable() {
-		return nil, fmt.Errorf("Groq API key not available")
-	}
-
-	// Groq uses OpenAI-compatible API
-	var apiMessages []map[string]string
-
-	if options != nil && options.SystemPrompt != "" {
-		apiMessages = append(apiMessages, map[string]string{
-			"role":    "system",
-			"content": options.SystemPrompt,
-		})
-	}
-
-	for _, msg := range messages {
-		apiMessages = append(apiMessages, map[string]string{
-			"role":    msg.Role,
-			"content": msg.Content,
-		})
-	}
-
-	reqData := map[string]interface{}{
-		"model":    g.model,
-		"messages": apiMessages,
-	}
-
-	if options != nil {
-		if options.Temperature > 0 {
-			reqData["temperature"] = options.Temperature
-		}
-		if options.MaxTokens > 0 {
-			reqData["max_tokens"] = options.MaxTokens
-		}
-	}
-
-	jsonData, err := json.Marshal(reqData)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.groq.com/openai/v1/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+g.apiKey)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("groq error: %s", string(body))
-	}
-
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Usage struct {
-			TotalTokens int `json:"total_tokens"`
-		} `json:"usage"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	if len(result.Choices) == 0 {
-		return nil, fmt.Errorf("no response from Groq")
-	}
-
-	return &Response{
-		Content:    result.Choices[0].Message.Content,
-		TokensUsed: result.Usage.TotalTokens,
-		Model:      g.model,
-		Metadata: map[string]interface{}{
-			"provider": "groq",
-		},
-	}, nil
+func (g *GroqProvider) SupportsVision() bool {
+	return strings.Contains(g.model, "vision") || strings.Contains(g.model, "llama-4")
 }
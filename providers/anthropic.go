@@ -0,0 +1,427 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"nero/providers/credentials"
+	"nero/providers/sse"
+)
+
+// Implement the Anthropic Messages API with streaming and extended thinking
+type AnthropicProvider struct {
+	apiKey         string
+	baseURL        string
+	model          string
+	thinkingBudget int
+	transport      *Transport
+}
+
+// Create a new Anthropic provider
+func NewAnthropicProvider(model string) *AnthropicProvider {
+	apiKey := credentials.Lookup("anthropic")
+
+	baseURL := os.Getenv("ANTHROPIC_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com/v1"
+	}
+
+	return &AnthropicProvider{
+		apiKey:         apiKey,
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		model:          model,
+		thinkingBudget: 2048,
+		transport:      NewTransport("anthropic", DefaultTimeout),
+	}
+}
+
+func (a *AnthropicProvider) Name() string {
+	return "anthropic"
+}
+
+func (a *AnthropicProvider) Model() string {
+	return a.model
+}
+
+func (a *AnthropicProvider) SetTimeout(timeout time.Duration) {
+	a.transport.SetTimeout(timeout)
+}
+
+func (a *AnthropicProvider) SetBaseURL(baseURL string) {
+	a.baseURL = strings.TrimRight(baseURL, "/")
+}
+
+func (a *AnthropicProvider) SetAPIKey(key string) {
+	a.apiKey = key
+}
+
+func (a *AnthropicProvider) IsAvailable() bool {
+	return a.apiKey != ""
+}
+
+func (a *AnthropicProvider) SupportsTools() bool {
+	return true
+}
+
+// Extended thinking is available from Claude 3.7 onwards
+func (a *AnthropicProvider) SupportsReasoning() bool {
+	return strings.Contains(a.model, "3-7") || strings.Contains(a.model, "-4")
+}
+
+func (a *AnthropicProvider) Chat(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
+	if !a.IsAvailable() {
+		return nil, fmt.Errorf("Anthropic API key not available")
+	}
+
+	if options != nil && options.Stream {
+		// Use streaming but collect full response, keeping thoughts out of the answer
+		response, err := collectReasoning(func(callback StreamCallback) error {
+			return a.ChatStream(ctx, messages, options, callback)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		response.Model = a.model
+		response.Metadata = map[string]interface{}{
+			"provider": "anthropic",
+		}
+		return &response.Response, nil
+	}
+
+	resp, err := a.post(ctx, a.messagesRequest(messages, options, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Content []anthropicBlock `json:"content"`
+		Usage   anthropicUsage   `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	var content strings.Builder
+	var toolCalls []ToolCall
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "tool_use":
+			toolCalls = append(toolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: block.Input})
+		}
+	}
+
+	return &Response{
+		Content:    content.String(),
+		ToolCalls:  toolCalls,
+		TokensUsed: result.Usage.InputTokens + result.Usage.OutputTokens,
+		Usage: &Usage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+			TotalTokens:      result.Usage.InputTokens + result.Usage.OutputTokens,
+		},
+		Model: a.model,
+		Metadata: map[string]interface{}{
+			"provider": "anthropic",
+		},
+	}, nil
+}
+
+// Implement streaming for Anthropic server-sent events
+func (a *AnthropicProvider) ChatStream(ctx context.Context, messages []Message, options *ChatOptions, callback StreamCallback) error {
+	if !a.IsAvailable() {
+		return fmt.Errorf("Anthropic API key not available")
+	}
+
+	resp, err := a.post(ctx, a.messagesRequest(messages, options, true))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	usage := &Usage{}
+	blocks := make(map[int]*anthropicBlock)
+	toolInputs := make(map[int]*strings.Builder)
+
+	decoder := sse.NewDecoder(resp.Body)
+	for {
+		message, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			callback(StreamChunk{Error: err, Done: true})
+			return err
+		}
+
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(message.Data), &event); err != nil {
+			continue // Skip malformed events
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.InputTokens
+
+		case "content_block_start":
+			block := event.ContentBlock
+			blocks[event.Index] = &block
+			if block.Type == "tool_use" {
+				toolInputs[event.Index] = &strings.Builder{}
+			}
+
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				callback(StreamChunk{Content: event.Delta.Text, Type: "text", Delta: true})
+			case "thinking_delta":
+				callback(StreamChunk{Content: event.Delta.Thinking, Type: "reasoning", IsThought: true, Delta: true})
+			case "input_json_delta":
+				if input, ok := toolInputs[event.Index]; ok {
+					input.WriteString(event.Delta.PartialJSON)
+				}
+			}
+
+		case "content_block_stop":
+			block, ok := blocks[event.Index]
+			if ok && block.Type == "tool_use" {
+				args := map[string]interface{}{}
+				if raw := toolInputs[event.Index].String(); raw != "" {
+					if err := json.Unmarshal([]byte(raw), &args); err != nil {
+						err = fmt.Errorf("invalid arguments for tool %s: %w", block.Name, err)
+						callback(StreamChunk{Error: err, Done: true})
+						return err
+					}
+				}
+				callback(StreamChunk{
+					Type:      "text",
+					ToolCalls: []ToolCall{{ID: block.ID, Name: block.Name, Arguments: args}},
+				})
+			}
+
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+
+		case "error":
+			err := fmt.Errorf("anthropic error: %s", event.Error.Message)
+			callback(StreamChunk{Error: err, Done: true})
+			return err
+		}
+
+		// Check for context cancellation
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	callback(StreamChunk{Done: true, Usage: usage})
+	return nil
+}
+
+// Stream with thinking enabled and collect thoughts separately from the answer
+func (a *AnthropicProvider) ChatWithReasoning(ctx context.Context, messages []Message, options *ChatOptions) (*ReasoningResponse, error) {
+	reasoningOptions := ChatOptions{}
+	if options != nil {
+		reasoningOptions = *options
+	}
+	reasoningOptions.EnableThoughts = true
+
+	response, err := collectReasoning(func(callback StreamCallback) error {
+		return a.ChatStream(ctx, messages, &reasoningOptions, callback)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response.Model = a.model
+	response.Metadata = map[string]interface{}{
+		"provider": "anthropic",
+	}
+	return response, nil
+}
+
+// Build a Messages API request body
+func (a *AnthropicProvider) messagesRequest(messages []Message, options *ChatOptions, stream bool) map[string]interface{} {
+	var system []string
+	if options != nil && options.SystemPrompt != "" {
+		system = append(system, options.SystemPrompt)
+	}
+
+	var apiMessages []map[string]interface{}
+	hasToolTurns := false
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			// Anthropic only accepts a top-level system prompt
+			system = append(system, msg.Content)
+		case "assistant":
+			var content []map[string]interface{}
+			if msg.Content != "" {
+				content = append(content, map[string]interface{}{"type": "text", "text": msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				hasToolTurns = true
+				content = append(content, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.ID,
+					"name":  call.Name,
+					"input": call.Arguments,
+				})
+			}
+			apiMessages = append(apiMessages, map[string]interface{}{"role": "assistant", "content": content})
+		case "tool":
+			hasToolTurns = true
+			apiMessages = append(apiMessages, map[string]interface{}{
+				"role": "user",
+				"content": []map[string]interface{}{{
+					"type":        "tool_result",
+					"tool_use_id": msg.ToolCallID,
+					"content":     msg.Content,
+				}},
+			})
+		default:
+			apiMessages = append(apiMessages, map[string]interface{}{"role": "user", "content": msg.Content})
+		}
+	}
+
+	maxTokens := 1024
+	if options != nil && options.MaxTokens > 0 {
+		maxTokens = options.MaxTokens
+	}
+
+	reqData := map[string]interface{}{
+		"model":      a.model,
+		"messages":   apiMessages,
+		"max_tokens": maxTokens,
+	}
+
+	if stream {
+		reqData["stream"] = true
+	}
+
+	if len(system) > 0 {
+		reqData["system"] = strings.Join(system, "\n\n")
+	}
+
+	if options == nil {
+		return reqData
+	}
+
+	// Thinking blocks must be echoed back on tool turns, which Message cannot carry
+	thinking := options.EnableThoughts && a.SupportsReasoning() && !hasToolTurns
+	if thinking {
+		reqData["thinking"] = map[string]interface{}{
+			"type":          "enabled",
+			"budget_tokens": a.thinkingBudget,
+		}
+		// The answer budget comes on top of the thinking budget
+		reqData["max_tokens"] = maxTokens + a.thinkingBudget
+	} else {
+		if options.Temperature > 0 {
+			reqData["temperature"] = options.Temperature
+		}
+		if options.TopP > 0 {
+			reqData["top_p"] = options.TopP
+		}
+	}
+
+	if len(options.Stop) > 0 {
+		reqData["stop_sequences"] = options.Stop
+	}
+
+	if len(options.Tools) > 0 {
+		var tools []map[string]interface{}
+		for _, tool := range options.Tools {
+			tools = append(tools, map[string]interface{}{
+				"name":         tool.Name,
+				"description":  tool.Description,
+				"input_schema": tool.Parameters,
+			})
+		}
+		reqData["tools"] = tools
+
+		switch options.ToolChoice {
+		case "required":
+			reqData["tool_choice"] = map[string]string{"type": "any"}
+		case "none":
+			reqData["tool_choice"] = map[string]string{"type": "none"}
+		}
+	}
+
+	return reqData
+}
+
+// Send a Messages API request and return the raw response
+func (a *AnthropicProvider) post(ctx context.Context, reqData map[string]interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := a.transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Represent a content block in responses and stream events
+type anthropicBlock struct {
+	Type     string                 `json:"type"`
+	Text     string                 `json:"text"`
+	Thinking string                 `json:"thinking"`
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Input    map[string]interface{} `json:"input"`
+}
+
+// Represent Anthropic token accounting
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Represent a single streamed event
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock anthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
@@ -0,0 +1,540 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Choose how a cassette provider treats requests
+type CassetteMode string
+
+const (
+	CassetteRecord CassetteMode = "record" // Always call the real provider and record the result
+	CassetteReplay CassetteMode = "replay" // Only answer from the cassette; misses are errors
+	CassetteAuto   CassetteMode = "auto"   // Replay recorded requests, record new ones
+)
+
+// Hold recorded interactions for one or more providers in a single file
+type Cassette struct {
+	path         string
+	interactions []*Interaction
+	cursors      map[string]int // Next interaction to replay per request hash
+	mu           sync.Mutex
+}
+
+// Record one request and what the provider answered
+type Interaction struct {
+	Hash      string          `json:"hash"`
+	Provider  string          `json:"provider"`
+	Model     string          `json:"model,omitempty"`
+	Request   json.RawMessage `json:"request"` // Normalized request, kept for reading diffs
+	Response  *Response       `json:"response,omitempty"`
+	Chunks    []RecordedChunk `json:"chunks,omitempty"` // Set when the request was streamed
+	Error     string          `json:"error,omitempty"`
+	ErrorKind ErrorKind       `json:"error_kind,omitempty"`
+}
+
+// Record a stream chunk with its offset from the start of the stream
+type RecordedChunk struct {
+	Offset    time.Duration `json:"offset"`
+	Content   string        `json:"content,omitempty"`
+	Type      string        `json:"type,omitempty"`
+	IsThought bool          `json:"is_thought,omitempty"`
+	ToolCalls []ToolCall    `json:"tool_calls,omitempty"`
+	Usage     *Usage        `json:"usage,omitempty"`
+	Delta     bool          `json:"delta,omitempty"`
+	Done      bool          `json:"done,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Open a cassette file, starting empty if it does not exist yet
+func OpenCassette(path string) (*Cassette, error) {
+	cassette := &Cassette{
+		path:    path,
+		cursors: make(map[string]int),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cassette, nil
+		}
+		return nil, err
+	}
+
+	var file struct {
+		Interactions []*Interaction `json:"interactions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	cassette.interactions = file.Interactions
+
+	return cassette, nil
+}
+
+// List the providers that appear in the cassette
+func (c *Cassette) Providers() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, interaction := range c.interactions {
+		if !seen[interaction.Provider] {
+			seen[interaction.Provider] = true
+			names = append(names, interaction.Provider)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Wrap a real provider so its traffic is recorded or replayed
+func (c *Cassette) Wrap(inner AIProvider, mode CassetteMode) *CassetteProvider {
+	provider := &CassetteProvider{
+		cassette: c,
+		inner:    inner,
+		name:     inner.Name(),
+		mode:     mode,
+	}
+	if modelProvider, ok := inner.(ModelProvider); ok {
+		provider.model = modelProvider.Model()
+	}
+	return provider
+}
+
+// Create a replay-only provider for a recorded provider name, needing no network
+func (c *Cassette) Replay(name string) *CassetteProvider {
+	provider := &CassetteProvider{
+		cassette: c,
+		name:     name,
+		mode:     CassetteReplay,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, interaction := range c.interactions {
+		if interaction.Provider == name && interaction.Model != "" {
+			provider.model = interaction.Model
+		}
+	}
+	return provider
+}
+
+// Find the next recorded interaction for a hash; repeated requests replay in recording order
+func (c *Cassette) next(hash string) *Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []*Interaction
+	for _, interaction := range c.interactions {
+		if interaction.Hash == hash {
+			matches = append(matches, interaction)
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	// Once every recording has been used, keep answering with the last one
+	i := min(c.cursors[hash], len(matches)-1)
+	c.cursors[hash]++
+	return matches[i]
+}
+
+// Append an interaction and rewrite the file
+func (c *Cassette) add(interaction *Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interactions = append(c.interactions, interaction)
+	c.cursors[interaction.Hash]++ // Replaying later in the same run should not return this one again
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"interactions": c.interactions,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// Record or replay a provider's chat calls, forwarding the capabilities that need no recording
+type CassetteProvider struct {
+	cassette *Cassette
+	inner    AIProvider // nil for replay-only providers
+	name     string
+	model    string
+	mode     CassetteMode
+	realtime bool
+}
+
+// Replay streams with their recorded chunk timing instead of as fast as possible
+func (p *CassetteProvider) SetRealtime(realtime bool) {
+	p.realtime = realtime
+}
+
+func (p *CassetteProvider) Name() string {
+	return p.name
+}
+
+func (p *CassetteProvider) Model() string {
+	return p.model
+}
+
+func (p *CassetteProvider) IsAvailable() bool {
+	if p.mode == CassetteReplay {
+		return true
+	}
+	return p.inner != nil && p.inner.IsAvailable()
+}
+
+func (p *CassetteProvider) SupportsTools() bool {
+	if toolProvider, ok := p.inner.(ToolProvider); ok {
+		return toolProvider.SupportsTools()
+	}
+	return p.inner == nil // Replay answers whatever was recorded
+}
+
+func (p *CassetteProvider) SupportsVision() bool {
+	if vision, ok := p.inner.(VisionProvider); ok {
+		return vision.SupportsVision()
+	}
+	return p.inner == nil
+}
+
+func (p *CassetteProvider) SupportsReasoning() bool {
+	if reasoning, ok := p.inner.(ReasoningProvider); ok {
+		return reasoning.SupportsReasoning()
+	}
+	return false
+}
+
+// Collect reasoning from the recorded stream, so it replays like any other request
+func (p *CassetteProvider) ChatWithReasoning(ctx context.Context, messages []Message, options *ChatOptions) (*ReasoningResponse, error) {
+	reasoningOptions := ChatOptions{}
+	if options != nil {
+		reasoningOptions = *options
+	}
+	reasoningOptions.EnableThoughts = true
+
+	response, err := collectReasoning(func(callback StreamCallback) error {
+		return p.ChatStream(ctx, messages, &reasoningOptions, callback)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response.Model = p.model
+	response.Metadata = map[string]interface{}{
+		"provider": p.name,
+	}
+	return response, nil
+}
+
+func (p *CassetteProvider) SetTimeout(timeout time.Duration) {
+	if timeoutProvider, ok := p.inner.(TimeoutProvider); ok {
+		timeoutProvider.SetTimeout(timeout)
+	}
+}
+
+func (p *CassetteProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if lister, ok := p.inner.(ModelLister); ok {
+		return lister.ListModels(ctx)
+	}
+	return nil, fmt.Errorf("provider %s cannot list models", p.name)
+}
+
+// Switch the real provider's model and keep recording under the new one; replay-only providers replay its recordings
+func (p *CassetteProvider) WithModel(model string) AIProvider {
+	clone := *p
+	clone.model = model
+	if p.inner == nil {
+		return &clone
+	}
+
+	switcher, ok := p.inner.(ModelSwitcher)
+	if !ok {
+		return p
+	}
+	clone.inner = switcher.WithModel(model)
+	return &clone
+}
+
+func (p *CassetteProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if embedder, ok := p.inner.(EmbeddingProvider); ok {
+		return embedder.Embed(ctx, texts)
+	}
+	return nil, fmt.Errorf("provider %s does not support embeddings", p.name)
+}
+
+func (p *CassetteProvider) Dimensions() int {
+	if embedder, ok := p.inner.(EmbeddingProvider); ok {
+		return embedder.Dimensions()
+	}
+	return 0
+}
+
+func (p *CassetteProvider) Chat(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
+	return p.record(ctx, messages, options, func(inner AIProvider) (*Response, error) {
+		return inner.Chat(ctx, messages, options)
+	})
+}
+
+func (p *CassetteProvider) ChatWithVision(ctx context.Context, messages []VisionMessage, options *ChatOptions) (*Response, error) {
+	return p.record(ctx, messages, options, func(inner AIProvider) (*Response, error) {
+		vision, ok := inner.(VisionProvider)
+		if !ok {
+			return nil, fmt.Errorf("provider %s does not support vision", p.name)
+		}
+		return vision.ChatWithVision(ctx, messages, options)
+	})
+}
+
+// Replay a non-streamed request, or send it to the real provider with call and record the answer
+func (p *CassetteProvider) record(ctx context.Context, messages interface{}, options *ChatOptions, call func(inner AIProvider) (*Response, error)) (*Response, error) {
+	hash, request := p.requestHash(messages, options)
+
+	if interaction := p.lookup(hash); interaction != nil {
+		return interaction.response()
+	}
+	if p.mode == CassetteReplay || p.inner == nil {
+		return nil, p.miss(hash)
+	}
+
+	response, err := call(p.inner)
+	if ctx.Err() != nil {
+		return response, err // Cancellations say nothing about the provider
+	}
+
+	interaction := p.interaction(hash, request)
+	interaction.Response = response
+	interaction.setError(err)
+	if saveErr := p.cassette.add(interaction); saveErr != nil && err == nil {
+		return nil, fmt.Errorf("cassette error: %w", saveErr)
+	}
+
+	return response, err
+}
+
+func (p *CassetteProvider) ChatStream(ctx context.Context, messages []Message, options *ChatOptions, callback StreamCallback) error {
+	hash, request := p.requestHash(messages, options)
+
+	if interaction := p.lookup(hash); interaction != nil {
+		return p.replayStream(ctx, interaction, callback)
+	}
+	if p.mode == CassetteReplay || p.inner == nil {
+		err := p.miss(hash)
+		callback(StreamChunk{Error: err, Done: true})
+		return err
+	}
+
+	streamer, ok := p.inner.(StreamingProvider)
+	if !ok {
+		return fmt.Errorf("provider %s does not support streaming", p.name)
+	}
+
+	interaction := p.interaction(hash, request)
+	start := time.Now()
+
+	err := streamer.ChatStream(ctx, messages, options, func(chunk StreamChunk) {
+		recorded := RecordedChunk{
+			Offset:    time.Since(start),
+			Content:   chunk.Content,
+			Type:      chunk.Type,
+			IsThought: chunk.IsThought,
+			ToolCalls: chunk.ToolCalls,
+			Usage:     chunk.Usage,
+			Delta:     chunk.Delta,
+			Done:      chunk.Done,
+		}
+		if chunk.Error != nil {
+			recorded.Error = chunk.Error.Error()
+		}
+		interaction.Chunks = append(interaction.Chunks, recorded)
+		callback(chunk)
+	})
+	if ctx.Err() != nil {
+		return err
+	}
+
+	interaction.setError(err)
+	if saveErr := p.cassette.add(interaction); saveErr != nil && err == nil {
+		return fmt.Errorf("cassette error: %w", saveErr)
+	}
+	return err
+}
+
+// Replay recorded chunks, or a recorded Chat answer as a single chunk
+func (p *CassetteProvider) replayStream(ctx context.Context, interaction *Interaction, callback StreamCallback) error {
+	if interaction.Chunks == nil {
+		response, err := interaction.response()
+		if err != nil {
+			callback(StreamChunk{Error: err, Done: true})
+			return err
+		}
+		callback(StreamChunk{Content: response.Content, Type: "text", ToolCalls: response.ToolCalls, Usage: response.Usage, Delta: true, Done: true})
+		return nil
+	}
+
+	start := time.Now()
+	for _, recorded := range interaction.Chunks {
+		if p.realtime {
+			select {
+			case <-time.After(recorded.Offset - time.Since(start)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		chunk := StreamChunk{
+			Content:   recorded.Content,
+			Type:      recorded.Type,
+			IsThought: recorded.IsThought,
+			ToolCalls: recorded.ToolCalls,
+			Usage:     recorded.Usage,
+			Delta:     recorded.Delta,
+			Done:      recorded.Done,
+		}
+		if recorded.Error != "" {
+			chunk.Error = errors.New(recorded.Error)
+		}
+		callback(chunk)
+	}
+
+	if interaction.Error != "" {
+		return interaction.err(p.name)
+	}
+	return nil
+}
+
+// Look up a recording unless the cassette is only recording
+func (p *CassetteProvider) lookup(hash string) *Interaction {
+	if p.mode == CassetteRecord {
+		return nil
+	}
+	return p.cassette.next(hash)
+}
+
+// Report a request that was never recorded
+func (p *CassetteProvider) miss(hash string) error {
+	return &ProviderError{
+		Provider: p.name,
+		Kind:     ErrorRequest,
+		Message:  fmt.Sprintf("no recording for request %s in cassette %s", hash[:12], p.cassette.path),
+	}
+}
+
+// Start an interaction for a request about to be recorded
+func (p *CassetteProvider) interaction(hash string, request json.RawMessage) *Interaction {
+	return &Interaction{
+		Hash:     hash,
+		Provider: p.name,
+		Model:    p.model,
+		Request:  request,
+	}
+}
+
+// Hash the parts of a request that affect the answer; streaming and whitespace do not
+func (p *CassetteProvider) requestHash(messages interface{}, options *ChatOptions) (string, json.RawMessage) {
+	normalized := messages
+	if chat, ok := messages.([]Message); ok {
+		trimmed := make([]Message, len(chat))
+		for i, msg := range chat {
+			msg.Content = normalizeText(msg.Content)
+			trimmed[i] = msg
+		}
+		normalized = trimmed
+	}
+
+	var opts ChatOptions
+	if options != nil {
+		opts = *options
+	}
+	opts.Stream = false
+	opts.SystemPrompt = normalizeText(opts.SystemPrompt)
+
+	request, _ := json.Marshal(map[string]interface{}{
+		"provider": p.name,
+		"model":    p.model,
+		"messages": normalized,
+		"options":  opts,
+	})
+
+	sum := sha256.Sum256(request)
+	return hex.EncodeToString(sum[:]), request
+}
+
+// Ignore line-ending and surrounding whitespace differences
+func normalizeText(text string) string {
+	return strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+}
+
+// Store a provider error so replays fail the same way
+func (i *Interaction) setError(err error) {
+	if err == nil {
+		return
+	}
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		i.Error = providerErr.Message // Error() adds the provider prefix again on replay
+		i.ErrorKind = providerErr.Kind
+		return
+	}
+	i.Error = err.Error()
+}
+
+// Rebuild the recorded error, keeping its kind so fallbacks behave as they did
+func (i *Interaction) err(provider string) error {
+	if i.ErrorKind == "" {
+		return errors.New(i.Error)
+	}
+	return &ProviderError{Provider: provider, Kind: i.ErrorKind, Message: i.Error}
+}
+
+// Return the recorded answer, assembling it from chunks if the request was streamed
+func (i *Interaction) response() (*Response, error) {
+	if i.Error != "" {
+		return nil, i.err(i.Provider)
+	}
+	if i.Response != nil || i.Chunks == nil {
+		response := Response{}
+		if i.Response != nil {
+			response = *i.Response
+		}
+		return &response, nil
+	}
+
+	var content strings.Builder
+	response := &Response{Model: i.Model}
+	for _, chunk := range i.Chunks {
+		if chunk.IsThought {
+			continue
+		}
+		content.WriteString(chunk.Content)
+		response.ToolCalls = append(response.ToolCalls, chunk.ToolCalls...)
+		if chunk.Usage != nil {
+			response.Usage = chunk.Usage
+			response.TokensUsed = chunk.Usage.TotalTokens
+		}
+	}
+	response.Content = content.String()
+	return response, nil
+}
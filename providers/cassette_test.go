@@ -0,0 +1,120 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Answer chat completions with a fixed reply, streamed when asked, counting the calls
+func chatServer(t *testing.T, reply string, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if !body.Stream {
+			fmt.Fprintf(w, `{"choices":[{"message":{"content":%q}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`, reply)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\ndata: [DONE]\n\n", reply)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	messages := []Message{{Role: "user", Content: "hi"}}
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	server := chatServer(t, "Hello!", &calls)
+	real := NewCompatibleProvider(CompatibleConfig{Name: "local", BaseURL: server.URL, Model: "tiny"})
+
+	cassette, err := OpenCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := cassette.Wrap(real, CassetteRecord)
+	if _, err := recorder.Chat(ctx, messages, nil); err != nil {
+		t.Fatalf("record Chat: %v", err)
+	}
+	if err := recorder.ChatStream(ctx, messages, nil, func(StreamChunk) {}); err != nil {
+		t.Fatalf("record ChatStream: %v", err)
+	}
+	server.Close()
+
+	// Replay from the file alone, with the server gone
+	cassette, err = OpenCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := cassette.Replay("local")
+	if replay.Model() != "tiny" {
+		t.Errorf("replay model = %q, want tiny", replay.Model())
+	}
+
+	response, err := replay.Chat(ctx, messages, nil)
+	if err != nil || response.Content != "Hello!" {
+		t.Errorf("replay Chat = %+v, %v; want Hello!", response, err)
+	}
+
+	var streamed string
+	if err := replay.ChatStream(ctx, messages, nil, func(chunk StreamChunk) { streamed += chunk.Content }); err != nil || streamed != "Hello!" {
+		t.Errorf("replay ChatStream = %q, %v; want Hello!", streamed, err)
+	}
+
+	if _, err := replay.Chat(ctx, []Message{{Role: "user", Content: "something new"}}, nil); ErrorKindOf(err) != ErrorRequest {
+		t.Errorf("unrecorded request: err = %v, want a request error", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("server called %d times, want 2", calls.Load())
+	}
+}
+
+func TestCassetteForwardsCapabilities(t *testing.T) {
+	cassette, err := OpenCassette(filepath.Join(t.TempDir(), "cassette.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := NewCompatibleProvider(CompatibleConfig{Name: "local", BaseURL: "http://127.0.0.1:1", Model: "llava", Vision: true})
+	var provider AIProvider = cassette.Wrap(real, CassetteAuto)
+
+	if vision, ok := provider.(VisionProvider); !ok || !vision.SupportsVision() {
+		t.Error("wrapped provider lost vision support")
+	}
+
+	timeouts, ok := provider.(TimeoutProvider)
+	if !ok {
+		t.Fatal("wrapped provider lost its timeout")
+	}
+	timeouts.SetTimeout(time.Second)
+	if real.transport.currentTimeout() != time.Second {
+		t.Errorf("timeout = %v, want it set on the real provider", real.transport.currentTimeout())
+	}
+
+	switcher, ok := provider.(ModelSwitcher)
+	if !ok {
+		t.Fatal("wrapped provider cannot switch models")
+	}
+	switched, ok := switcher.WithModel("qwen2.5").(*CassetteProvider)
+	if !ok || switched.Model() != "qwen2.5" || switched.inner.(ModelProvider).Model() != "qwen2.5" {
+		t.Errorf("WithModel = %#v, want a cassette provider bound to qwen2.5", switched)
+	}
+	if provider.(ModelProvider).Model() != "llava" {
+		t.Error("WithModel changed the original provider")
+	}
+}
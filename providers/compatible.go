@@ -0,0 +1,352 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"nero/providers/credentials"
+	"nero/providers/sse"
+)
+
+// Describe an OpenAI-compatible endpoint (LM Studio, vLLM, llama.cpp, OpenRouter, ...)
+type CompatibleConfig struct {
+	Name      string            `json:"name"`
+	BaseURL   string            `json:"base_url"`
+	APIKeyEnv string            `json:"api_key_env,omitempty"` // Empty for servers that need no key
+	Headers   map[string]string `json:"headers,omitempty"`
+	Model     string            `json:"model"`
+	Vision    bool              `json:"vision,omitempty"` // Model accepts image inputs
+}
+
+// Implement any OpenAI-compatible chat completions API
+type CompatibleProvider struct {
+	name        string
+	apiKey      string
+	baseURL     string
+	headers     map[string]string
+	model       string
+	keyRequired bool
+	vision      bool
+	transport   *Transport
+}
+
+// Create a provider for an OpenAI-compatible endpoint
+func NewCompatibleProvider(config CompatibleConfig) *CompatibleProvider {
+	provider := &CompatibleProvider{
+		name:    config.Name,
+		baseURL: strings.TrimRight(config.BaseURL, "/"),
+		headers: config.Headers,
+		model:   config.Model,
+		vision:  config.Vision,
+		// Compatible servers are often local and slow to load a model
+		transport: NewTransport(config.Name, LocalTimeout),
+	}
+
+	// Keys come from the configured variable or the credential store under the endpoint's name
+	provider.apiKey = credentials.Resolve(config.Name, config.APIKeyEnv)
+	provider.keyRequired = config.APIKeyEnv != ""
+
+	return provider
+}
+
+// Create a compatible provider for a hosted API that always needs a key
+func newHostedProvider(name, baseURL, apiKey, model string) *CompatibleProvider {
+	return &CompatibleProvider{
+		name:        name,
+		apiKey:      apiKey,
+		baseURL:     baseURL,
+		model:       model,
+		keyRequired: true,
+		transport:   NewTransport(name, DefaultTimeout),
+	}
+}
+
+func (c *CompatibleProvider) Name() string {
+	return c.name
+}
+
+func (c *CompatibleProvider) IsAvailable() bool {
+	return !c.keyRequired || c.apiKey != ""
+}
+
+func (c *CompatibleProvider) Model() string {
+	return c.model
+}
+
+func (c *CompatibleProvider) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimRight(baseURL, "/")
+}
+
+func (c *CompatibleProvider) SetAPIKey(key string) {
+	c.apiKey = key
+}
+
+func (c *CompatibleProvider) SetTimeout(timeout time.Duration) {
+	c.transport.SetTimeout(timeout)
+}
+
+func (c *CompatibleProvider) SupportsTools() bool {
+	return true
+}
+
+func (c *CompatibleProvider) SupportsVision() bool {
+	return c.vision
+}
+
+func (c *CompatibleProvider) SupportsReasoning() bool {
+	return isReasoningModel(c.model)
+}
+
+// Stream the request and collect reasoning (inline <think> or reasoning_content) separately
+func (c *CompatibleProvider) ChatWithReasoning(ctx context.Context, messages []Message, options *ChatOptions) (*ReasoningResponse, error) {
+	if !c.IsAvailable() {
+		return nil, fmt.Errorf("%s API key not available", c.name)
+	}
+
+	response, err := collectReasoning(func(callback StreamCallback) error {
+		return c.ChatStream(ctx, messages, options, callback)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response.Model = c.model
+	response.Metadata = map[string]interface{}{
+		"provider": c.name,
+	}
+	return response, nil
+}
+
+// Apply auth and any endpoint-specific headers
+func (c *CompatibleProvider) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+}
+
+func (c *CompatibleProvider) Chat(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
+	if !c.IsAvailable() {
+		return nil, fmt.Errorf("%s API key not available", c.name)
+	}
+
+	if options != nil && options.Stream {
+		// Use streaming but collect full response, keeping thoughts out of the answer
+		response, err := collectReasoning(func(callback StreamCallback) error {
+			return c.ChatStream(ctx, messages, options, callback)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		response.Model = c.model
+		response.Metadata = map[string]interface{}{
+			"provider": c.name,
+		}
+		return &response.Response, nil
+	}
+
+	return c.chatNonStreaming(ctx, messages, options)
+}
+
+func (c *CompatibleProvider) chatNonStreaming(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
+	return c.complete(ctx, openAIRequest(c.model, messages, options, false))
+}
+
+// Implement vision using OpenAI content parts
+func (c *CompatibleProvider) ChatWithVision(ctx context.Context, messages []VisionMessage, options *ChatOptions) (*Response, error) {
+	if !c.IsAvailable() {
+		return nil, fmt.Errorf("%s API key not available", c.name)
+	}
+
+	// Reuse the regular request builder for sampling options, then swap in content parts
+	reqData := openAIRequest(c.model, nil, options, false)
+	reqData["messages"] = openAIVisionMessages(messages, options)
+
+	response, err := c.complete(ctx, reqData)
+	if err != nil {
+		return nil, err
+	}
+	response.Metadata["vision"] = true
+	return response, nil
+}
+
+// Send a non-streaming chat completion request
+func (c *CompatibleProvider) complete(ctx context.Context, reqData map[string]interface{}) (*Response, error) {
+	jsonData, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content          string           `json:"content"`
+				ReasoningContent string           `json:"reasoning_content"`
+				ToolCalls        []openAIToolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage openAIUsage `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("no response from %s", c.name)
+	}
+
+	toolCalls, err := parseOpenAIToolCalls(result.Choices[0].Message.ToolCalls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep inline thoughts out of the answer
+	thoughts, content := splitThoughts(c.model, result.Choices[0].Message.Content)
+	if reasoning := result.Choices[0].Message.ReasoningContent; reasoning != "" {
+		thoughts = reasoning
+	}
+
+	response := &Response{
+		Content:    content,
+		ToolCalls:  toolCalls,
+		TokensUsed: result.Usage.TotalTokens,
+		Usage:      result.Usage.usage(),
+		Model:      c.model,
+		Metadata: map[string]interface{}{
+			"provider": c.name,
+		},
+	}
+	if thoughts != "" {
+		response.Metadata["thoughts"] = thoughts
+	}
+	return response, nil
+}
+
+// Implement streaming for OpenAI-compatible APIs
+func (c *CompatibleProvider) ChatStream(ctx context.Context, messages []Message, options *ChatOptions, callback StreamCallback) error {
+	reqData := openAIRequest(c.model, messages, options, true)
+
+	jsonData, err := json.Marshal(reqData)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.transport.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Tool call arguments arrive in fragments and are emitted once complete
+	var pending toolCallAccumulator
+	parser := newThinkParser(c.model)
+	var usage *Usage
+	finish := func() error {
+		for _, chunk := range parser.Flush() {
+			callback(chunk)
+		}
+		toolCalls, err := pending.Calls()
+		if err != nil {
+			callback(StreamChunk{Error: err, Done: true})
+			return err
+		}
+		callback(StreamChunk{ToolCalls: toolCalls, Usage: usage, Done: true})
+		return nil
+	}
+
+	// Process streaming response
+	decoder := sse.NewDecoder(resp.Body)
+
+	for {
+		event, err := decoder.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			callback(StreamChunk{Error: err, Done: true})
+			return err
+		}
+
+		if event.Data == "[DONE]" {
+			return finish()
+		}
+
+		var result struct {
+			Choices []struct {
+				Delta struct {
+					Content          string           `json:"content"`
+					ReasoningContent string           `json:"reasoning_content"`
+					ToolCalls        []openAIToolCall `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *openAIUsage `json:"usage"` // Final chunk, via stream_options.include_usage
+		}
+
+		if err := json.Unmarshal([]byte(event.Data), &result); err != nil {
+			continue // Skip malformed JSON
+		}
+
+		if result.Usage != nil {
+			usage = result.Usage.usage()
+		}
+
+		if len(result.Choices) > 0 {
+			delta := result.Choices[0].Delta
+			pending.Add(delta.ToolCalls)
+
+			if delta.ReasoningContent != "" {
+				callback(StreamChunk{
+					Content:   delta.ReasoningContent,
+					Type:      "reasoning",
+					IsThought: true,
+					Delta:     true,
+				})
+			}
+
+			for _, chunk := range parser.Feed(delta.Content) {
+				callback(chunk)
+			}
+		}
+
+		// Check for context cancellation
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	// Some compatible servers close the stream without sending [DONE]
+	return finish()
+}
@@ -0,0 +1,85 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Serve a chat completions stream one byte per flush, so every event and line arrives split
+func byteServer(t *testing.T, stream string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i := 0; i < len(stream); i++ {
+			fmt.Fprint(w, stream[i:i+1])
+			flusher.Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCompatibleChatStreamFragmented(t *testing.T) {
+	stream := ": connected\r\n\r\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\r\n\r\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"type\":\"function\",\"function\":{\"name\":\"get_time\",\"arguments\":\"{\\\"zone\\\":\"}}]}}]}\r\r" +
+		"data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"\\\"UTC\\\"}\"}}]}}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2,\"total_tokens\":5}}\n\n" +
+		"data: [DONE]"
+
+	server := byteServer(t, stream)
+	provider := NewCompatibleProvider(CompatibleConfig{Name: "test", BaseURL: server.URL, Model: "test-model"})
+
+	var content strings.Builder
+	var final StreamChunk
+	err := provider.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, func(chunk StreamChunk) {
+		if chunk.Done {
+			final = chunk
+			return
+		}
+		content.WriteString(chunk.Content)
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+
+	if got := content.String(); got != "Hello" {
+		t.Errorf("content = %q, want %q", got, "Hello")
+	}
+	if len(final.ToolCalls) != 1 || final.ToolCalls[0].Name != "get_time" || final.ToolCalls[0].Arguments["zone"] != "UTC" {
+		t.Errorf("tool calls = %+v, want get_time(zone=UTC)", final.ToolCalls)
+	}
+	if final.Usage == nil || final.Usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v, want 2 completion tokens", final.Usage)
+	}
+}
+
+func TestCompatibleChatStreamWithoutDone(t *testing.T) {
+	// Some servers close without [DONE] or a final blank line
+	server := byteServer(t, "data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}")
+	provider := NewCompatibleProvider(CompatibleConfig{Name: "test", BaseURL: server.URL, Model: "test-model"})
+
+	var content strings.Builder
+	done := false
+	err := provider.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, func(chunk StreamChunk) {
+		content.WriteString(chunk.Content)
+		done = done || chunk.Done
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if content.String() != "partial" || !done {
+		t.Errorf("content = %q, done = %v; want %q and a final chunk", content.String(), done, "partial")
+	}
+}
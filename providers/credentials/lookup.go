@@ -0,0 +1,127 @@
+package credentials
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// Environment variable holding the store passphrase for non-interactive use
+const PassphraseEnv = "NERO_PASSPHRASE"
+
+var (
+	defaultOnce  sync.Once
+	defaultStore *Store
+	warnedFiles  sync.Map
+)
+
+// Resolve a provider's API key: NAME_API_KEY, then the credential store, then a legacy name.key file
+func Lookup(name string) string {
+	return Resolve(name, strings.ToUpper(name)+"_API_KEY")
+}
+
+// Resolve a provider's API key using a specific environment variable (empty to skip it)
+func Resolve(name, envVar string) string {
+	if envVar != "" {
+		if key := strings.TrimSpace(os.Getenv(envVar)); key != "" {
+			return key
+		}
+	}
+
+	if store := Default(); store != nil {
+		if key, exists := store.Get(name); exists {
+			return key
+		}
+	}
+
+	return legacyKey(name)
+}
+
+// Resolve a key reference from the config file: env:VAR or store:name; anything else falls back to Lookup(name)
+func ResolveRef(name, ref string) string {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		return strings.TrimSpace(os.Getenv(strings.TrimPrefix(ref, "env:")))
+	case strings.HasPrefix(ref, "store:"):
+		return Resolve(strings.TrimPrefix(ref, "store:"), "")
+	default:
+		return Lookup(name)
+	}
+}
+
+// Open the default store once per process, prompting for the passphrase if needed
+func Default() *Store {
+	defaultOnce.Do(func() {
+		path := DefaultPath()
+		if !Exists(path) {
+			return
+		}
+
+		passphrase, err := Passphrase(false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Credential store locked: %v\n", err)
+			return
+		}
+
+		store, err := Open(path, passphrase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not open credential store: %v\n", err)
+			return
+		}
+		defaultStore = store
+	})
+	return defaultStore
+}
+
+// Read the passphrase from NERO_PASSPHRASE or the terminal, asking twice when creating a store
+func Passphrase(confirm bool) ([]byte, error) {
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("no terminal to prompt on; set %s", PassphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "🔐 Credential store passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "🔐 Repeat passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		if string(again) != string(passphrase) {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+// Read a plaintext name.key file from the working directory, warning once that it is deprecated
+func legacyKey(name string) string {
+	filename := name + ".key"
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+
+	if _, warned := warnedFiles.LoadOrStore(filename, true); !warned {
+		fmt.Fprintf(os.Stderr, "⚠️  Reading %s from the working directory; move it into the credential store with: nero auth set %s\n", filename, name)
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,212 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Returned when the store cannot be decrypted with the given passphrase
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted credential store")
+
+// scrypt cost parameters for new stores; existing stores keep the ones they were written with
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	keyLength    = 32 // AES-256
+	saltLength   = 16
+	storeVersion = 1
+)
+
+// Bounds on the scrypt parameters read from a store, so a tampered file cannot demand gigabytes or hours
+const (
+	minScryptN = 1 << 10
+	maxScryptN = 1 << 20
+	maxScryptR = 16
+	maxScryptP = 16
+)
+
+// Hold API keys encrypted at rest with AES-GCM
+type Store struct {
+	path       string
+	passphrase []byte
+	secrets    map[string]string
+}
+
+// On-disk layout; everything but the ciphertext is needed to derive the key
+type storeFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Return the default store location under ~/.nero
+func DefaultPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".nero", "credentials")
+}
+
+// Check whether a store has been created at path
+func Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Open and decrypt the store at path, or start an empty one if it does not exist yet
+func Open(path string, passphrase []byte) (*Store, error) {
+	store := &Store{
+		path:       path,
+		passphrase: passphrase,
+		secrets:    make(map[string]string),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid credential store %s: %w", path, err)
+	}
+	if file.Version != storeVersion || file.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported credential store version %d (%s)", file.Version, file.KDF)
+	}
+	if !validScrypt(file.N, file.R, file.P) {
+		return nil, fmt.Errorf("invalid credential store %s: scrypt parameters N=%d r=%d p=%d out of range", path, file.N, file.R, file.P)
+	}
+
+	gcm, err := newGCM(passphrase, file.Salt, file.N, file.R, file.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid credential store %s: nonce is %d bytes", path, len(file.Nonce))
+	}
+
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	if err := json.Unmarshal(plaintext, &store.secrets); err != nil {
+		return nil, fmt.Errorf("invalid credential store contents: %w", err)
+	}
+
+	return store, nil
+}
+
+// Get the key stored for a provider
+func (s *Store) Get(name string) (string, bool) {
+	key, exists := s.secrets[name]
+	return key, exists
+}
+
+// Store a provider's key and save
+func (s *Store) Set(name, key string) error {
+	s.secrets[name] = key
+	return s.save()
+}
+
+// Delete a provider's key and save
+func (s *Store) Remove(name string) error {
+	if _, exists := s.secrets[name]; !exists {
+		return fmt.Errorf("no credential stored for %s", name)
+	}
+	delete(s.secrets, name)
+	return s.save()
+}
+
+// List the providers with stored keys
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.secrets))
+	for name := range s.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Encrypt with a fresh salt and nonce and replace the file atomically
+func (s *Store) save() error {
+	plaintext, err := json.Marshal(s.secrets)
+	if err != nil {
+		return err
+	}
+
+	file := storeFile{
+		Version: storeVersion,
+		KDF:     "scrypt",
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    make([]byte, saltLength),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(s.passphrase, file.Salt, file.N, file.R, file.P)
+	if err != nil {
+		return err
+	}
+
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Ciphertext = gcm.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Check that N is a power of two and every parameter is within the bounds above
+func validScrypt(n, r, p int) bool {
+	return n >= minScryptN && n <= maxScryptN && n&(n-1) == 0 &&
+		r >= 1 && r <= maxScryptR &&
+		p >= 1 && p <= maxScryptP
+}
+
+// Derive the store key from the passphrase and build the AEAD
+func newGCM(passphrase, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, n, r, p, keyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
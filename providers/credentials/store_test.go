@@ -0,0 +1,108 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	store, err := Open(path, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Open new: %v", err)
+	}
+	if err := store.Set("openai", "sk-test"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set("groq", "gsk-test"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Remove("groq"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-test") {
+		t.Error("key stored in plain text")
+	}
+
+	reopened, err := Open(path, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Open existing: %v", err)
+	}
+	if key, ok := reopened.Get("openai"); !ok || key != "sk-test" {
+		t.Errorf("openai = %q, %v; want sk-test", key, ok)
+	}
+	if names := reopened.Names(); len(names) != 1 {
+		t.Errorf("names = %v, want only openai", names)
+	}
+}
+
+func TestStoreWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	store, err := Open(path, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("openai", "sk-test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(path, []byte("battery staple")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("err = %v, want ErrWrongPassphrase", err)
+	}
+}
+
+func TestStoreRejectsUnsafeParameters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	store, err := Open(path, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("openai", "sk-test"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		edit func(*storeFile)
+	}{
+		{"huge N", func(f *storeFile) { f.N = 1 << 30 }},
+		{"N not a power of two", func(f *storeFile) { f.N = 30000 }},
+		{"zero r", func(f *storeFile) { f.R = 0 }},
+		{"huge p", func(f *storeFile) { f.P = 1 << 20 }},
+		{"short nonce", func(f *storeFile) { f.Nonce = f.Nonce[:4] }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file storeFile
+			if err := json.Unmarshal(data, &file); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(&file)
+			tampered, _ := json.Marshal(file)
+			if err := os.WriteFile(path, tampered, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Open(path, []byte("correct horse")); err == nil || errors.Is(err, ErrWrongPassphrase) {
+				t.Errorf("err = %v, want the store rejected as invalid", err)
+			}
+		})
+	}
+}
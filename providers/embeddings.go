@@ -0,0 +1,168 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Define embedding capability interface
+type EmbeddingProvider interface {
+	AIProvider
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Dimensions() int // 0 until known, either from the model table or the first Embed call
+}
+
+// Known output sizes of common embedding models
+var embeddingDimensions = map[string]int{
+	"nomic-embed-text":       768,
+	"mxbai-embed-large":      1024,
+	"all-minilm":             384,
+	"snowflake-arctic-embed": 1024,
+	"bge-m3":                 1024,
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+}
+
+// Remember the dimensions reported by an embedding model
+type dimensionCache struct {
+	mu         sync.RWMutex
+	dimensions map[string]int
+}
+
+// Share learned dimensions across provider instances
+var learnedDimensions = &dimensionCache{dimensions: make(map[string]int)}
+
+// Look up a model's dimensions, ignoring any Ollama tag suffix
+func (d *dimensionCache) get(model string) int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if dims, exists := d.dimensions[model]; exists {
+		return dims
+	}
+	base, _, _ := strings.Cut(model, ":")
+	return embeddingDimensions[base]
+}
+
+// Record dimensions from a response
+func (d *dimensionCache) set(model string, embeddings [][]float32) {
+	if len(embeddings) == 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dimensions[model] = len(embeddings[0])
+}
+
+// Implement embeddings via Ollama /api/embed
+func (o *OllamaProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"model": o.model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/embed", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d inputs", len(result.Embeddings), len(texts))
+	}
+
+	learnedDimensions.set(o.model, result.Embeddings)
+	return result.Embeddings, nil
+}
+
+func (o *OllamaProvider) Dimensions() int {
+	return learnedDimensions.get(o.model)
+}
+
+// Implement embeddings via the OpenAI /embeddings endpoint
+func (c *CompatibleProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if !c.IsAvailable() {
+		return nil, fmt.Errorf("%s API key not available", c.name)
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"model": c.model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("%s returned %d embeddings for %d inputs", c.name, len(result.Data), len(texts))
+	}
+
+	// Results carry their input index and are not guaranteed to be in order
+	embeddings := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("%s returned embedding for unknown input %d", c.name, item.Index)
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+
+	learnedDimensions.set(c.model, embeddings)
+	return embeddings, nil
+}
+
+func (c *CompatibleProvider) Dimensions() int {
+	return learnedDimensions.get(c.model)
+}
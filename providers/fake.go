@@ -0,0 +1,321 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Environment variable naming the script that selects the fake provider
+const FakeScriptEnv = "NERO_FAKE_SCRIPT"
+
+// Describe how the fake provider answers
+type FakeScript struct {
+	Model   string     `json:"model,omitempty"`   // Reported model name, "fake" by default
+	Latency string     `json:"latency,omitempty"` // Delay between streamed chunks, e.g. "40ms"
+	Rules   []FakeRule `json:"rules"`             // Checked in order; the first match answers
+	Default *FakeRule  `json:"default,omitempty"` // Used when no rule matches; echoes the message if unset
+}
+
+// Map a pattern on the last message to a scripted reply
+type FakeRule struct {
+	Match        string     `json:"match,omitempty"` // Regular expression; empty matches anything
+	Role         string     `json:"role,omitempty"`  // Only match when the last message has this role, e.g. "tool"
+	Response     string     `json:"response,omitempty"`
+	Thoughts     string     `json:"thoughts,omitempty"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	Latency      string     `json:"latency,omitempty"`       // Overrides the script latency
+	Delay        string     `json:"delay,omitempty"`         // Wait before the first chunk, like a model loading
+	Error        ErrorKind  `json:"error,omitempty"`         // Fail with this kind instead of answering
+	ErrorMessage string     `json:"error_message,omitempty"` // Defaults to a generic message for the kind
+
+	pattern *regexp.Regexp
+	latency time.Duration
+	delay   time.Duration
+}
+
+// Answer from a script, with no network; useful for tests and demos
+type FakeProvider struct {
+	script  *FakeScript
+	latency time.Duration
+}
+
+// Load and validate a fake provider script
+func LoadFakeScript(path string) (*FakeScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var script FakeScript
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("invalid fake script %s: %w", path, err)
+	}
+	return &script, nil
+}
+
+// Create a fake provider from a script; nil echoes every message back
+func NewFakeProvider(script *FakeScript) (*FakeProvider, error) {
+	if script == nil {
+		script = &FakeScript{}
+	}
+
+	latency, err := parseFakeDuration(script.Latency)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range script.Rules {
+		if err := script.Rules[i].compile(latency); err != nil {
+			return nil, fmt.Errorf("fake script rule %d: %w", i+1, err)
+		}
+	}
+	if script.Default != nil {
+		if err := script.Default.compile(latency); err != nil {
+			return nil, fmt.Errorf("fake script default: %w", err)
+		}
+	}
+
+	return &FakeProvider{script: script, latency: latency}, nil
+}
+
+// Create a fake provider from the script file at path
+func NewFakeProviderFromFile(path string) (*FakeProvider, error) {
+	script, err := LoadFakeScript(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFakeProvider(script)
+}
+
+// Prepare a rule's pattern and durations
+func (r *FakeRule) compile(latency time.Duration) error {
+	if r.Match != "" {
+		pattern, err := regexp.Compile(r.Match)
+		if err != nil {
+			return err
+		}
+		r.pattern = pattern
+	}
+
+	r.latency = latency
+	if r.Latency != "" {
+		ruleLatency, err := parseFakeDuration(r.Latency)
+		if err != nil {
+			return err
+		}
+		r.latency = ruleLatency
+	}
+
+	delay, err := parseFakeDuration(r.Delay)
+	if err != nil {
+		return err
+	}
+	r.delay = delay
+
+	switch r.Error {
+	case "", ErrorAuth, ErrorRateLimit, ErrorContextLength, ErrorServer, ErrorNetwork, ErrorTimeout, ErrorUnsupported, ErrorRequest:
+	default:
+		return fmt.Errorf("unknown error kind %q", r.Error)
+	}
+
+	return nil
+}
+
+func parseFakeDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return duration, nil
+}
+
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (f *FakeProvider) IsAvailable() bool {
+	return true
+}
+
+func (f *FakeProvider) Model() string {
+	if f.script.Model != "" {
+		return f.script.Model
+	}
+	return "fake"
+}
+
+func (f *FakeProvider) SupportsTools() bool {
+	return true
+}
+
+func (f *FakeProvider) SupportsReasoning() bool {
+	return true
+}
+
+func (f *FakeProvider) Chat(ctx context.Context, messages []Message, options *ChatOptions) (*Response, error) {
+	response, err := f.ChatWithReasoning(ctx, messages, options)
+	if err != nil {
+		return nil, err
+	}
+	return &response.Response, nil
+}
+
+func (f *FakeProvider) ChatWithReasoning(ctx context.Context, messages []Message, options *ChatOptions) (*ReasoningResponse, error) {
+	rule, content := f.match(messages)
+	if err := sleepContext(ctx, rule.delay); err != nil {
+		return nil, err
+	}
+	if rule.Error != "" {
+		return nil, rule.err()
+	}
+
+	usage := fakeUsage(messages, options, content)
+	return &ReasoningResponse{
+		Response: Response{
+			Content:    content,
+			ToolCalls:  rule.ToolCalls,
+			Usage:      usage,
+			TokensUsed: usage.TotalTokens,
+			Model:      f.Model(),
+			Metadata: map[string]interface{}{
+				"provider": "fake",
+			},
+		},
+		Thoughts: rule.Thoughts,
+	}, nil
+}
+
+func (f *FakeProvider) ChatStream(ctx context.Context, messages []Message, options *ChatOptions, callback StreamCallback) error {
+	rule, content := f.match(messages)
+	if err := sleepContext(ctx, rule.delay); err != nil {
+		return err
+	}
+	if rule.Error != "" {
+		err := rule.err()
+		callback(StreamChunk{Error: err, Done: true})
+		return err
+	}
+
+	var chunks []StreamChunk
+	if options != nil && options.EnableThoughts {
+		for _, word := range splitWords(rule.Thoughts) {
+			chunks = append(chunks, StreamChunk{Content: word, Type: "reasoning", IsThought: true, Delta: true})
+		}
+	}
+	for _, word := range splitWords(content) {
+		chunks = append(chunks, StreamChunk{Content: word, Type: "text", Delta: true})
+	}
+
+	for i, chunk := range chunks {
+		if i > 0 {
+			if err := sleepContext(ctx, rule.latency); err != nil {
+				return err
+			}
+		}
+		callback(chunk)
+	}
+
+	callback(StreamChunk{ToolCalls: rule.ToolCalls, Usage: fakeUsage(messages, options, content), Done: true})
+	return nil
+}
+
+// Pick the first rule matching the last message and expand $1-style references in its response
+func (f *FakeProvider) match(messages []Message) (*FakeRule, string) {
+	var last Message
+	if len(messages) > 0 {
+		last = messages[len(messages)-1]
+	}
+
+	for i := range f.script.Rules {
+		rule := &f.script.Rules[i]
+		if rule.Role != "" && rule.Role != last.Role {
+			continue
+		}
+		if rule.pattern == nil {
+			return rule, rule.Response
+		}
+		if submatches := rule.pattern.FindStringSubmatchIndex(last.Content); submatches != nil {
+			expanded := rule.pattern.ExpandString(nil, rule.Response, last.Content, submatches)
+			return rule, string(expanded)
+		}
+	}
+
+	if f.script.Default != nil {
+		return f.script.Default, f.script.Default.Response
+	}
+	return &FakeRule{latency: f.latency}, "(fake) " + last.Content
+}
+
+// Build the error a rule injects
+func (r *FakeRule) err() error {
+	message := r.ErrorMessage
+	if message == "" {
+		message = fmt.Sprintf("injected %s failure", r.Error)
+	}
+
+	providerErr := &ProviderError{Provider: "fake", Kind: r.Error, Message: message}
+	switch r.Error {
+	case ErrorAuth:
+		providerErr.StatusCode = 401
+	case ErrorRateLimit:
+		providerErr.StatusCode = 429
+	case ErrorServer:
+		providerErr.StatusCode = 500
+	case ErrorUnsupported:
+		providerErr.StatusCode = 404
+	case ErrorRequest, ErrorContextLength:
+		providerErr.StatusCode = 400
+	}
+	return providerErr
+}
+
+// Split text into word-sized chunks that keep their trailing whitespace
+func splitWords(text string) []string {
+	var words []string
+	for text != "" {
+		end := strings.IndexAny(text, " \n")
+		if end < 0 {
+			words = append(words, text)
+			break
+		}
+		words = append(words, text[:end+1])
+		text = text[end+1:]
+	}
+	return words
+}
+
+// Estimate usage the way the ledger does for providers that report none (~4 characters per token)
+func fakeUsage(messages []Message, options *ChatOptions, content string) *Usage {
+	promptChars := 0
+	if options != nil {
+		promptChars += len(options.SystemPrompt)
+	}
+	for _, msg := range messages {
+		promptChars += len(msg.Content)
+	}
+
+	usage := &Usage{PromptTokens: promptChars / 4, CompletionTokens: len(content) / 4}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// Wait for d unless the context ends first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,245 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Describe a model a provider can serve
+type ModelInfo struct {
+	Name        string
+	DisplayName string    // Human-friendly name, when the provider has one
+	Size        int64     // Bytes on disk, for local models
+	Modified    time.Time // When a local model was pulled or a hosted one was created
+}
+
+// Define model discovery capability
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// Define runtime model switching: return a copy of the provider bound to another model
+type ModelSwitcher interface {
+	WithModel(model string) AIProvider
+}
+
+// Check whether a listed model matches a requested name, allowing Ollama's implicit :latest tag
+func ModelMatches(listed, requested string) bool {
+	return listed == requested || listed == requested+":latest"
+}
+
+// Sort models by name for stable display
+func sortModels(models []ModelInfo) []ModelInfo {
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Name < models[j].Name
+	})
+	return models
+}
+
+// List locally installed models via /api/tags
+func (o *OllamaProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", o.baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := o.transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Models []struct {
+			Name       string    `json:"name"`
+			Size       int64     `json:"size"`
+			ModifiedAt time.Time `json:"modified_at"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(result.Models))
+	for _, model := range result.Models {
+		models = append(models, ModelInfo{Name: model.Name, Size: model.Size, Modified: model.ModifiedAt})
+	}
+	return sortModels(models), nil
+}
+
+func (o *OllamaProvider) WithModel(model string) AIProvider {
+	clone := *o
+	clone.model = model
+	return &clone
+}
+
+// List models via the OpenAI /models endpoint
+func (c *CompatibleProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if !c.IsAvailable() {
+		return nil, fmt.Errorf("%s API key not available", c.name)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(result.Data))
+	for _, model := range result.Data {
+		info := ModelInfo{Name: model.ID}
+		if model.Created > 0 {
+			info.Modified = time.Unix(model.Created, 0)
+		}
+		models = append(models, info)
+	}
+	return sortModels(models), nil
+}
+
+func (c *CompatibleProvider) WithModel(model string) AIProvider {
+	clone := *c
+	clone.model = model
+	return &clone
+}
+
+// Keep the OpenAI wrapper so vision detection follows the new model
+func (o *OpenAIProvider) WithModel(model string) AIProvider {
+	return &OpenAIProvider{CompatibleProvider: o.CompatibleProvider.WithModel(model).(*CompatibleProvider)}
+}
+
+func (g *GroqProvider) WithModel(model string) AIProvider {
+	return &GroqProvider{CompatibleProvider: g.CompatibleProvider.WithModel(model).(*CompatibleProvider)}
+}
+
+// List models that support generateContent via models.list, following pagination
+func (g *GeminiProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if !g.IsAvailable() {
+		return nil, fmt.Errorf("Gemini API key not available")
+	}
+
+	var models []ModelInfo
+	pageToken := ""
+
+	for {
+		endpoint := fmt.Sprintf("%s/models?pageSize=1000&key=%s", g.baseURL, g.apiKey)
+		if pageToken != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := g.transport.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Models []struct {
+				Name                       string   `json:"name"`
+				DisplayName                string   `json:"displayName"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, model := range result.Models {
+			for _, method := range model.SupportedGenerationMethods {
+				if method == "generateContent" {
+					models = append(models, ModelInfo{
+						Name:        strings.TrimPrefix(model.Name, "models/"),
+						DisplayName: model.DisplayName,
+					})
+					break
+				}
+			}
+		}
+
+		if result.NextPageToken == "" {
+			break
+		}
+		pageToken = result.NextPageToken
+	}
+
+	return sortModels(models), nil
+}
+
+func (g *GeminiProvider) WithModel(model string) AIProvider {
+	clone := *g
+	clone.model = model
+	return &clone
+}
+
+// List models via the Anthropic /models endpoint
+func (a *AnthropicProvider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if !a.IsAvailable() {
+		return nil, fmt.Errorf("Anthropic API key not available")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", a.baseURL+"/models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := a.transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data []struct {
+			ID          string    `json:"id"`
+			DisplayName string    `json:"display_name"`
+			CreatedAt   time.Time `json:"created_at"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(result.Data))
+	for _, model := range result.Data {
+		models = append(models, ModelInfo{Name: model.ID, DisplayName: model.DisplayName, Modified: model.CreatedAt})
+	}
+	return sortModels(models), nil
+}
+
+func (a *AnthropicProvider) WithModel(model string) AIProvider {
+	clone := *a
+	clone.model = model
+	return &clone
+}
@@ -0,0 +1,194 @@
+package providers
+
+import (
+	"strings"
+	"time"
+)
+
+// Tags that local reasoning models (deepseek-r1, qwq, qwen3, ...) wrap their thoughts in
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// Split inline <think>…</think> spans out of streamed text, even when a tag is split across chunks.
+// Tags are only parsed for reasoning models and for streams that open with <think>, so other
+// answers can mention the tag literally
+type thinkParser struct {
+	reasoning bool // The model is known to think out loud
+	decided   bool // The stream's opening has been seen
+	parsing   bool // Tags are being split out
+	inThought bool
+	pending   string // Opening or partial tag held back until the next chunk
+	closed    bool   // A thought just ended; blank lines before the answer are dropped
+}
+
+// Create a parser for a stream from model
+func newThinkParser(model string) *thinkParser {
+	return &thinkParser{reasoning: isReasoningModel(model)}
+}
+
+// Consume the next piece of streamed text and return the resulting chunks
+func (p *thinkParser) Feed(text string) []StreamChunk {
+	var chunks []StreamChunk
+	text = p.pending + text
+	p.pending = ""
+
+	if !p.decided {
+		opening := strings.TrimLeft(text, " \t\r\n")
+		if len(opening) < len(thinkOpenTag) && strings.HasPrefix(thinkOpenTag, opening) {
+			p.pending = text // Too early to tell whether a thought opens the stream
+			return nil
+		}
+
+		p.decided = true
+		p.parsing = p.reasoning || strings.HasPrefix(opening, thinkOpenTag)
+		if strings.HasPrefix(opening, thinkOpenTag) {
+			text = opening
+		}
+	}
+
+	if !p.parsing {
+		return p.emit(chunks, text)
+	}
+
+	for text != "" {
+		tag := thinkOpenTag
+		if p.inThought {
+			tag = thinkCloseTag
+		}
+
+		if i := strings.Index(text, tag); i >= 0 {
+			chunks = p.emit(chunks, text[:i])
+			text = text[i+len(tag):]
+			p.inThought = !p.inThought
+			p.closed = !p.inThought
+			continue
+		}
+
+		// Hold back a trailing partial tag until we know whether it completes
+		keep := partialTagSuffix(text, tag)
+		chunks = p.emit(chunks, text[:len(text)-keep])
+		p.pending = text[len(text)-keep:]
+		break
+	}
+
+	return chunks
+}
+
+// Release anything still held back once the stream has ended
+func (p *thinkParser) Flush() []StreamChunk {
+	text := p.pending
+	p.pending = ""
+	return p.emit(nil, text)
+}
+
+// Append text as a thought or answer chunk
+func (p *thinkParser) emit(chunks []StreamChunk, text string) []StreamChunk {
+	if p.inThought {
+		if text == "" {
+			return chunks
+		}
+		return append(chunks, StreamChunk{Content: text, Type: "reasoning", IsThought: true, Delta: true})
+	}
+
+	// Drop the blank lines models put between the thoughts and the answer
+	if p.closed {
+		text = strings.TrimLeft(text, " \t\r\n")
+	}
+	if text == "" {
+		return chunks
+	}
+
+	p.closed = false
+	return append(chunks, StreamChunk{Content: text, Type: "text", Delta: true})
+}
+
+// Return the length of the longest suffix of text that is a prefix of tag
+func partialTagSuffix(text, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// Separate inline thoughts from a complete (non-streamed) response by model
+func splitThoughts(model, text string) (string, string) {
+	parser := newThinkParser(model)
+	var thoughts, answer strings.Builder
+
+	for _, chunk := range append(parser.Feed(text), parser.Flush()...) {
+		if chunk.IsThought {
+			thoughts.WriteString(chunk.Content)
+		} else {
+			answer.WriteString(chunk.Content)
+		}
+	}
+
+	return strings.TrimSpace(thoughts.String()), answer.String()
+}
+
+// Check whether a model name belongs to a family that thinks out loud
+func isReasoningModel(model string) bool {
+	model = strings.ToLower(model)
+	for _, family := range []string{"deepseek-r1", "qwq", "qwen3", "magistral", "phi4-reasoning", "gpt-oss", "think", "reason"} {
+		if strings.Contains(model, family) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run a streaming request and collect thoughts and answer separately, timing the thinking phase
+func collectReasoning(stream func(callback StreamCallback) error) (*ReasoningResponse, error) {
+	var thoughts, content strings.Builder
+	var toolCalls []ToolCall
+	var usage *Usage
+	var thinkStart, thinkEnd time.Time
+
+	err := stream(func(chunk StreamChunk) {
+		if chunk.Error != nil {
+			return
+		}
+		if chunk.IsThought {
+			if thinkStart.IsZero() {
+				thinkStart = time.Now()
+			}
+			thoughts.WriteString(chunk.Content)
+			return
+		}
+		if chunk.Content != "" && !thinkStart.IsZero() && thinkEnd.IsZero() {
+			thinkEnd = time.Now()
+		}
+		content.WriteString(chunk.Content)
+		toolCalls = append(toolCalls, chunk.ToolCalls...)
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ReasoningResponse{
+		Response: Response{
+			Content:   content.String(),
+			ToolCalls: toolCalls,
+		},
+		Thoughts: thoughts.String(),
+	}
+	if usage != nil {
+		response.TokensUsed = usage.TotalTokens
+		response.Usage = usage
+	}
+	if !thinkStart.IsZero() {
+		if thinkEnd.IsZero() {
+			thinkEnd = time.Now()
+		}
+		response.ThoughtsTime = thinkEnd.Sub(thinkStart)
+	}
+
+	return response, nil
+}
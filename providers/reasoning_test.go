@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+)
+
+// Feed text to a parser in pieces of size bytes, returning the thoughts and answer
+func parseThoughts(model, text string, size int) (string, string) {
+	parser := newThinkParser(model)
+	var chunks []StreamChunk
+	for len(text) > 0 {
+		n := min(size, len(text))
+		chunks = append(chunks, parser.Feed(text[:n])...)
+		text = text[n:]
+	}
+	chunks = append(chunks, parser.Flush()...)
+
+	var thoughts, answer strings.Builder
+	for _, chunk := range chunks {
+		if chunk.IsThought {
+			thoughts.WriteString(chunk.Content)
+		} else {
+			answer.WriteString(chunk.Content)
+		}
+	}
+	return thoughts.String(), answer.String()
+}
+
+func TestThinkParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		text     string
+		thoughts string
+		answer   string
+	}{
+		{
+			name:     "reasoning model",
+			model:    "deepseek-r1:8b",
+			text:     "<think>Add them.</think>\n\n4",
+			thoughts: "Add them.",
+			answer:   "4",
+		},
+		{
+			name:     "stream opening with a thought",
+			model:    "llama3.2",
+			text:     "\n<think>Hm.</think>\n  Done",
+			thoughts: "Hm.",
+			answer:   "Done",
+		},
+		{
+			name:   "literal tag in an ordinary answer",
+			model:  "llama3.2",
+			text:   "Wrap it in <think> and </think> tags.",
+			answer: "Wrap it in <think> and </think> tags.",
+		},
+		{
+			name:   "indented code",
+			model:  "llama3.2",
+			text:   "    return x\n",
+			answer: "    return x\n",
+		},
+		{
+			name:   "indented code from a reasoning model",
+			model:  "qwen3",
+			text:   "\tif ok {\n\t}",
+			answer: "\tif ok {\n\t}",
+		},
+		{
+			name:   "short answer",
+			model:  "llama3.2",
+			text:   "<t",
+			answer: "<t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, size := range []int{1, 3, len(tt.text)} {
+				thoughts, answer := parseThoughts(tt.model, tt.text, size)
+				if thoughts != tt.thoughts || answer != tt.answer {
+					t.Errorf("size %d: got (%q, %q), want (%q, %q)", size, thoughts, answer, tt.thoughts, tt.answer)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Represent a single dispatched event
+type Event struct {
+	Type  string        // Value of the event field, "message" when none was sent
+	ID    string        // Last event ID seen on the stream
+	Data  string        // Data lines joined with "\n"
+	Retry time.Duration // Reconnection delay requested by the server, if any
+}
+
+// Read events from a stream, regardless of how the bytes are split across reads
+type Decoder struct {
+	reader  *bufio.Reader
+	lastID  string
+	started bool
+}
+
+// Create a decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: bufio.NewReader(r)}
+}
+
+// Return the next event, or io.EOF once the stream has ended
+func (d *Decoder) Next() (*Event, error) {
+	var data bytes.Buffer
+	var eventType string
+	var retry time.Duration
+	hasData := false
+
+	dispatch := func() *Event {
+		event := &Event{
+			Type:  eventType,
+			ID:    d.lastID,
+			Data:  strings.TrimSuffix(data.String(), "\n"),
+			Retry: retry,
+		}
+		if event.Type == "" {
+			event.Type = "message"
+		}
+		return event
+	}
+
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			// Servers often close without the final blank line; keep what was sent
+			if err == io.EOF && hasData {
+				return dispatch(), nil
+			}
+			return nil, err
+		}
+
+		if line == "" {
+			if hasData {
+				return dispatch(), nil
+			}
+			// A blank line without data ends an empty event, which is not dispatched
+			eventType, retry = "", 0
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue // Comment, often used as a keep-alive
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// Read one line terminated by LF, CRLF or a lone CR
+func (d *Decoder) readLine() (string, error) {
+	var line []byte
+
+	for {
+		b, err := d.reader.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return d.strip(line), nil
+			}
+			return "", err
+		}
+
+		switch b {
+		case '\n':
+			return d.strip(line), nil
+		case '\r':
+			if next, err := d.reader.Peek(1); err == nil && next[0] == '\n' {
+				d.reader.ReadByte()
+			}
+			return d.strip(line), nil
+		}
+
+		line = append(line, b)
+	}
+}
+
+// Drop the UTF-8 byte order mark a stream may start with
+func (d *Decoder) strip(line []byte) string {
+	if !d.started {
+		d.started = true
+		line = bytes.TrimPrefix(line, []byte("\xEF\xBB\xBF"))
+	}
+	return string(line)
+}
@@ -0,0 +1,137 @@
+package sse
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// Read every event from a stream
+func decodeAll(t *testing.T, r io.Reader) []Event {
+	t.Helper()
+
+	var events []Event
+	decoder := NewDecoder(r)
+	for {
+		event, err := decoder.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		events = append(events, *event)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []Event
+	}{
+		{
+			name:   "lf endings",
+			stream: "data: one\n\ndata: two\n\n",
+			want:   []Event{{Type: "message", Data: "one"}, {Type: "message", Data: "two"}},
+		},
+		{
+			name:   "crlf endings",
+			stream: "data: one\r\n\r\ndata: two\r\n\r\n",
+			want:   []Event{{Type: "message", Data: "one"}, {Type: "message", Data: "two"}},
+		},
+		{
+			name:   "lone cr endings",
+			stream: "data: one\r\rdata: two\r\r",
+			want:   []Event{{Type: "message", Data: "one"}, {Type: "message", Data: "two"}},
+		},
+		{
+			name:   "multi-line data",
+			stream: "data: first\ndata: second\ndata\n\n",
+			want:   []Event{{Type: "message", Data: "first\nsecond\n"}},
+		},
+		{
+			name:   "comments",
+			stream: ": keep-alive\ndata: one\n: between lines\n\n:\n\n",
+			want:   []Event{{Type: "message", Data: "one"}},
+		},
+		{
+			name:   "no final blank line",
+			stream: "data: one\n\ndata: two",
+			want:   []Event{{Type: "message", Data: "one"}, {Type: "message", Data: "two"}},
+		},
+		{
+			name:   "byte order mark",
+			stream: "\xEF\xBB\xBFdata: one\n\n",
+			want:   []Event{{Type: "message", Data: "one"}},
+		},
+		{
+			name:   "fields",
+			stream: "event: delta\nid: 7\nretry: 1500\ndata:no space\n\ndata: next\n\n",
+			want: []Event{
+				{Type: "delta", ID: "7", Data: "no space", Retry: 1500 * time.Millisecond},
+				{Type: "message", ID: "7", Data: "next"},
+			},
+		},
+		{
+			name:   "empty event is not dispatched",
+			stream: "event: ping\n\ndata: one\n\n",
+			want:   []Event{{Type: "message", Data: "one"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Whole, and one byte per read so every line and field is split
+			for _, r := range []io.Reader{
+				strings.NewReader(tt.stream),
+				iotest.OneByteReader(strings.NewReader(tt.stream)),
+			} {
+				if got := decodeAll(t, r); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// Replay a stream from a server that flushes it in small pieces
+func TestDecoderFragmentedServer(t *testing.T) {
+	stream := "\xEF\xBB\xBF: hello\r\n" +
+		"data: {\"a\":\r\n" +
+		"data: 1}\r\n\r\n" +
+		"event: done\rdata: [DONE]"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		sizes := []int{1, 2, 3, 5, 8}
+		for i, rest := 0, stream; len(rest) > 0; i++ {
+			n := min(sizes[i%len(sizes)], len(rest))
+			fmt.Fprint(w, rest[:n])
+			flusher.Flush()
+			rest = rest[n:]
+		}
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	want := []Event{
+		{Type: "message", Data: "{\"a\":\n1}"},
+		{Type: "done", Data: "[DONE]"},
+	}
+	if got := decodeAll(t, resp.Body); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,297 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Describe the shape a response must take
+type ResponseFormat struct {
+	Type   string                 `json:"type"`             // "json" for free JSON, "json_schema" for a supplied schema
+	Name   string                 `json:"name,omitempty"`   // Schema name, required by OpenAI
+	Schema map[string]interface{} `json:"schema,omitempty"` // JSON Schema object
+}
+
+// Request any valid JSON object
+func JSONFormat() *ResponseFormat {
+	return &ResponseFormat{Type: "json"}
+}
+
+// Request JSON matching the given schema
+func SchemaFormat(name string, schema map[string]interface{}) *ResponseFormat {
+	return &ResponseFormat{Type: "json_schema", Name: name, Schema: schema}
+}
+
+// Complete a conversation and return the raw text, used by CompleteJSON
+type CompleteFunc func(ctx context.Context, messages []Message) (string, error)
+
+// Ask a provider for JSON and decode it into T, retrying once on invalid output
+func ChatJSON[T any](ctx context.Context, provider AIProvider, messages []Message, options *ChatOptions) (T, error) {
+	jsonOptions := ChatOptions{}
+	if options != nil {
+		jsonOptions = *options
+	}
+	if jsonOptions.ResponseFormat == nil {
+		jsonOptions.ResponseFormat = JSONFormat()
+	}
+	jsonOptions.Stream = false
+	jsonOptions.SystemPrompt = strings.TrimSpace(jsonOptions.SystemPrompt + "\n\n" + jsonOptions.ResponseFormat.Instruction())
+
+	return CompleteJSON[T](ctx, func(ctx context.Context, messages []Message) (string, error) {
+		response, err := provider.Chat(ctx, messages, &jsonOptions)
+		if err != nil {
+			return "", err
+		}
+		return response.Content, nil
+	}, messages, jsonOptions.ResponseFormat)
+}
+
+// Run complete, validate the output against the format and retry once with the error
+func CompleteJSON[T any](ctx context.Context, complete CompleteFunc, messages []Message, format *ResponseFormat) (T, error) {
+	var result T
+
+	content, err := complete(ctx, messages)
+	if err != nil {
+		return result, err
+	}
+
+	result, err = DecodeJSON[T](content, format)
+	if err == nil {
+		return result, nil
+	}
+
+	// Show the model its mistake and give it one more chance
+	retry := append(append([]Message(nil), messages...),
+		Message{Role: "assistant", Content: content},
+		Message{Role: "user", Content: fmt.Sprintf("That output was invalid: %v. Reply again with only the corrected JSON.", err)},
+	)
+
+	content, err = complete(ctx, retry)
+	if err != nil {
+		return result, err
+	}
+
+	return DecodeJSON[T](content, format)
+}
+
+// Validate a JSON response against the format's schema and unmarshal it into T
+func DecodeJSON[T any](content string, format *ResponseFormat) (T, error) {
+	var result T
+	content = stripCodeFence(content)
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return result, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+
+	if format != nil && format.Schema != nil {
+		if err := ValidateJSON(value, format.Schema); err != nil {
+			return result, err
+		}
+	}
+
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return result, fmt.Errorf("response does not match %T: %w", result, err)
+	}
+
+	return result, nil
+}
+
+// Check a decoded JSON value against a JSON Schema (type, properties, required, items, enum)
+func ValidateJSON(value interface{}, schema map[string]interface{}) error {
+	return validateJSON(value, schema, "$")
+}
+
+func validateJSON(value interface{}, schema map[string]interface{}, path string) error {
+	if expected, ok := schema["type"].(string); ok && !jsonTypeMatches(value, expected) {
+		return fmt.Errorf("%s: expected %s, got %s", path, expected, jsonTypeName(value))
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range enum {
+			if fmt.Sprint(option) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, exists := v[name]; !exists {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			propSchema, known := properties[name].(map[string]interface{})
+			if !known {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s: unexpected field %q", path, name)
+				}
+				continue
+			}
+			if err := validateJSON(v[name], propSchema, path+"."+name); err != nil {
+				return err
+			}
+		}
+
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateJSON(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Check a decoded value against a JSON Schema type name
+func jsonTypeMatches(value interface{}, expected string) bool {
+	switch expected {
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == float64(int64(number))
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonTypeName(value) == expected
+	}
+}
+
+// Name the JSON type of a decoded value
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// Read a schema keyword that may be []string or a decoded []interface{}
+func schemaStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// Remove the ```json fences models like to wrap JSON in
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+
+	content = strings.TrimPrefix(content, "```")
+	if newline := strings.Index(content, "\n"); newline >= 0 {
+		content = content[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
+
+// Describe the format in words for providers without a native JSON mode
+func (f *ResponseFormat) Instruction() string {
+	if f.Schema == nil {
+		return "Respond with a single JSON object and nothing else."
+	}
+
+	schema, _ := json.Marshal(f.Schema)
+	return fmt.Sprintf("Respond with a single JSON object matching this JSON Schema and nothing else:\n%s", schema)
+}
+
+// Map the format onto OpenAI's response_format
+func (f *ResponseFormat) OpenAI() map[string]interface{} {
+	if f.Schema == nil {
+		return map[string]interface{}{"type": "json_object"}
+	}
+
+	name := f.Name
+	if name == "" {
+		name = "response"
+	}
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   name,
+			"schema": f.Schema,
+		},
+	}
+}
+
+// Map the format onto Ollama's format field
+func (f *ResponseFormat) Ollama() interface{} {
+	if f.Schema == nil {
+		return "json"
+	}
+	return f.Schema
+}
+
+// Convert a JSON Schema to the OpenAPI subset Gemini accepts in responseSchema
+func geminiSchema(schema map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+
+	for key, value := range schema {
+		switch key {
+		case "type":
+			if s, ok := value.(string); ok {
+				result["type"] = strings.ToUpper(s)
+			}
+		case "description", "format", "nullable", "enum", "required", "minItems", "maxItems":
+			result[key] = value
+		case "items":
+			if items, ok := value.(map[string]interface{}); ok {
+				result["items"] = geminiSchema(items)
+			}
+		case "properties":
+			if properties, ok := value.(map[string]interface{}); ok {
+				converted := map[string]interface{}{}
+				for name, prop := range properties {
+					if propSchema, ok := prop.(map[string]interface{}); ok {
+						converted[name] = geminiSchema(propSchema)
+					}
+				}
+				result["properties"] = converted
+			}
+		}
+	}
+
+	return result
+}
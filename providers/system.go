@@ -224,30 +224,38 @@ func (k *KaomojiProvider) GenerateKaomoji(ctx context.Context, response string,
 Response: "%s"
 
 Rules:
-- Return ONLY the kaomoji, nothing else
 - Make it match the emotion and tone
 - Keep it simple and expressive
-- Examples: (´∀｀) (╯°□°）╯ (˘▾˘~) ♪(´▽｀) (￣ω￣) 
-
-Kaomoji:`, mood, response)
+- Examples: (´∀｀) (╯°□°）╯ (˘▾˘~) ♪(´▽｀) (￣ω￣)`, mood, response)
 
 	messages := []Message{
 		{Role: "user", Content: prompt},
 	}
 
 	options := &ChatOptions{
-		Temperature: 0.8,
-		MaxTokens:   20,
+		Temperature:    0.8,
+		MaxTokens:      60,
+		ResponseFormat: SchemaFormat("kaomoji", kaomojiSchema),
 	}
 
-	resp, err := k.aiProvider.Chat(ctx, messages, options)
+	result, err := ChatJSON[struct {
+		Kaomoji string `json:"kaomoji"`
+	}](ctx, k.aiProvider, messages, options)
 	if err != nil {
 		return "", err
 	}
 
-	// Clean up the response
-	kaomoji := strings.TrimSpace(resp.Content)
-	kaomoji = strings.Trim(kaomoji, "\"'`")
+	return strings.TrimSpace(result.Kaomoji), nil
+}
 
-	return kaomoji, nil
+// Constrain kaomoji generation to a single field
+var kaomojiSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"kaomoji": map[string]interface{}{
+			"type":        "string",
+			"description": "A single kaomoji, e.g. (´∀｀)",
+		},
+	},
+	"required": []string{"kaomoji"},
 }
@@ -0,0 +1,334 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Describe a function the model is allowed to call
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"` // JSON Schema object
+}
+
+// Represent a structured tool invocation requested by the model
+type ToolCall struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// Build the message that feeds a tool result back to the model
+func ToolResultMessage(call ToolCall, result string) Message {
+	return Message{
+		Role:       "tool",
+		Content:    result,
+		Name:       call.Name,
+		ToolCallID: call.ID,
+	}
+}
+
+// Convert messages to the OpenAI chat format, including tool turns
+func openAIMessages(messages []Message, options *ChatOptions) []map[string]interface{} {
+	var apiMessages []map[string]interface{}
+
+	if options != nil && options.SystemPrompt != "" {
+		apiMessages = append(apiMessages, map[string]interface{}{
+			"role":    "system",
+			"content": options.SystemPrompt,
+		})
+	}
+
+	for _, msg := range messages {
+		apiMsg := map[string]interface{}{
+			"role":    msg.Role,
+			"content": msg.Content,
+		}
+
+		if len(msg.ToolCalls) > 0 {
+			var calls []map[string]interface{}
+			for _, call := range msg.ToolCalls {
+				args, _ := json.Marshal(call.Arguments)
+				calls = append(calls, map[string]interface{}{
+					"id":   call.ID,
+					"type": "function",
+					"function": map[string]string{
+						"name":      call.Name,
+						"arguments": string(args),
+					},
+				})
+			}
+			apiMsg["tool_calls"] = calls
+		}
+
+		if msg.ToolCallID != "" {
+			apiMsg["tool_call_id"] = msg.ToolCallID
+		}
+
+		apiMessages = append(apiMessages, apiMsg)
+	}
+
+	return apiMessages
+}
+
+// Convert tool definitions to the OpenAI function format (also used by Ollama)
+func openAITools(tools []Tool) []map[string]interface{} {
+	var apiTools []map[string]interface{}
+	for _, tool := range tools {
+		apiTools = append(apiTools, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			},
+		})
+	}
+	return apiTools
+}
+
+// Build an OpenAI-compatible chat completion request body
+func openAIRequest(model string, messages []Message, options *ChatOptions, stream bool) map[string]interface{} {
+	reqData := map[string]interface{}{
+		"model":    model,
+		"messages": openAIMessages(messages, options),
+	}
+
+	if stream {
+		reqData["stream"] = true
+		// Ask for a final chunk with token counts
+		reqData["stream_options"] = map[string]bool{"include_usage": true}
+	}
+
+	if options != nil {
+		if options.Temperature > 0 {
+			reqData["temperature"] = options.Temperature
+		}
+		if options.MaxTokens > 0 {
+			reqData["max_tokens"] = options.MaxTokens
+		}
+		if options.TopP > 0 {
+			reqData["top_p"] = options.TopP
+		}
+		if len(options.Stop) > 0 {
+			reqData["stop"] = options.Stop
+		}
+		if options.Seed != nil {
+			reqData["seed"] = *options.Seed
+		}
+		if len(options.Tools) > 0 {
+			reqData["tools"] = openAITools(options.Tools)
+			if options.ToolChoice != "" {
+				reqData["tool_choice"] = options.ToolChoice
+			}
+		}
+		if options.ResponseFormat != nil {
+			reqData["response_format"] = options.ResponseFormat.OpenAI()
+		}
+	}
+
+	return reqData
+}
+
+// Represent a tool call in OpenAI wire format
+type openAIToolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// Represent token usage in OpenAI wire format
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *openAIUsage) usage() *Usage {
+	return &Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+// Decode OpenAI tool calls whose arguments arrive as JSON strings
+func parseOpenAIToolCalls(calls []openAIToolCall) ([]ToolCall, error) {
+	var result []ToolCall
+	for _, call := range calls {
+		args := map[string]interface{}{}
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments for tool %s: %w", call.Function.Name, err)
+			}
+		}
+		result = append(result, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: args,
+		})
+	}
+	return result, nil
+}
+
+// Accumulate streamed tool call fragments keyed by their index
+type toolCallAccumulator struct {
+	calls []openAIToolCall
+}
+
+// Merge a streamed delta into the pending calls
+func (a *toolCallAccumulator) Add(deltas []openAIToolCall) {
+	for _, delta := range deltas {
+		for len(a.calls) <= delta.Index {
+			a.calls = append(a.calls, openAIToolCall{Index: len(a.calls)})
+		}
+		call := &a.calls[delta.Index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		call.Function.Name += delta.Function.Name
+		call.Function.Arguments += delta.Function.Arguments
+	}
+}
+
+// Return the completed tool calls, if any were streamed
+func (a *toolCallAccumulator) Calls() ([]ToolCall, error) {
+	if len(a.calls) == 0 {
+		return nil, nil
+	}
+	return parseOpenAIToolCalls(a.calls)
+}
+
+// Convert messages to Gemini contents, mapping tool turns to function parts
+func geminiContents(messages []Message) []map[string]interface{} {
+	var contents []map[string]interface{}
+
+	for _, msg := range messages {
+		role := "user"
+		var parts []map[string]interface{}
+
+		switch msg.Role {
+		case "assistant":
+			role = "model"
+			if msg.Content != "" {
+				parts = append(parts, map[string]interface{}{"text": msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				parts = append(parts, map[string]interface{}{
+					"functionCall": map[string]interface{}{
+						"name": call.Name,
+						"args": call.Arguments,
+					},
+				})
+			}
+		case "tool":
+			parts = append(parts, map[string]interface{}{
+				"functionResponse": map[string]interface{}{
+					"name":     msg.Name,
+					"response": map[string]interface{}{"content": msg.Content},
+				},
+			})
+		default:
+			parts = append(parts, map[string]interface{}{"text": msg.Content})
+		}
+
+		contents = append(contents, map[string]interface{}{
+			"role":  role,
+			"parts": parts,
+		})
+	}
+
+	return contents
+}
+
+// Convert tool definitions to Gemini functionDeclarations
+func geminiTools(tools []Tool) []map[string]interface{} {
+	var declarations []map[string]interface{}
+	for _, tool := range tools {
+		declarations = append(declarations, map[string]interface{}{
+			"name":        tool.Name,
+			"description": tool.Description,
+			"parameters":  tool.Parameters,
+		})
+	}
+	return []map[string]interface{}{
+		{"functionDeclarations": declarations},
+	}
+}
+
+// Map a generic tool choice onto Gemini's function calling mode
+func geminiToolMode(choice string) string {
+	switch choice {
+	case "none":
+		return "NONE"
+	case "required":
+		return "ANY"
+	default:
+		return "AUTO"
+	}
+}
+
+// Convert messages to Ollama /api/chat format, including tool turns
+func ollamaMessages(messages []Message, options *ChatOptions) []map[string]interface{} {
+	var apiMessages []map[string]interface{}
+
+	if options != nil && options.SystemPrompt != "" {
+		apiMessages = append(apiMessages, map[string]interface{}{
+			"role":    "system",
+			"content": options.SystemPrompt,
+		})
+	}
+
+	for _, msg := range messages {
+		apiMsg := map[string]interface{}{
+			"role":    msg.Role,
+			"content": msg.Content,
+		}
+
+		if len(msg.ToolCalls) > 0 {
+			var calls []map[string]interface{}
+			for _, call := range msg.ToolCalls {
+				calls = append(calls, map[string]interface{}{
+					"function": map[string]interface{}{
+						"name":      call.Name,
+						"arguments": call.Arguments,
+					},
+				})
+			}
+			apiMsg["tool_calls"] = calls
+		}
+
+		if msg.Role == "tool" && msg.Name != "" {
+			apiMsg["tool_name"] = msg.Name
+		}
+
+		apiMessages = append(apiMessages, apiMsg)
+	}
+
+	return apiMessages
+}
+
+// Represent a tool call in Ollama wire format
+type ollamaToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+// Convert Ollama tool calls, synthesizing IDs since Ollama omits them
+func parseOllamaToolCalls(calls []ollamaToolCall) []ToolCall {
+	var result []ToolCall
+	for i, call := range calls {
+		result = append(result, ToolCall{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return result
+}
@@ -0,0 +1,330 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Classify provider failures so callers can decide whether to retry or fall back
+type ErrorKind string
+
+const (
+	ErrorAuth          ErrorKind = "auth"           // Missing, invalid or unauthorized key
+	ErrorRateLimit     ErrorKind = "rate_limit"     // 429, possibly with Retry-After
+	ErrorContextLength ErrorKind = "context_length" // Prompt does not fit the model's context window
+	ErrorServer        ErrorKind = "server"         // 5xx or overloaded
+	ErrorNetwork       ErrorKind = "network"        // Connection failures
+	ErrorTimeout       ErrorKind = "timeout"        // No response in time; not retried, as the server may still be working on it
+	ErrorUnsupported   ErrorKind = "unsupported"    // 404, unknown model or a feature the model lacks; another provider may manage
+	ErrorRequest       ErrorKind = "request"        // Any other rejected request, which every provider would reject too
+)
+
+// Describe a failed provider call
+type ProviderError struct {
+	Provider   string
+	Kind       ErrorKind
+	StatusCode int
+	RetryAfter time.Duration // Set for rate limits when the server says how long to wait
+	Message    string
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s error: %s", e.Provider, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Report whether the same request may succeed if sent again
+func (e *ProviderError) Retryable() bool {
+	return e.Kind == ErrorServer || e.Kind == ErrorNetwork || e.Kind == ErrorRateLimit
+}
+
+// Return the kind of a provider error, or "" for other errors
+func ErrorKindOf(err error) ErrorKind {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Kind
+	}
+	return ""
+}
+
+// Default time allowed for a provider to start responding
+const (
+	DefaultTimeout = 2 * time.Minute
+	LocalTimeout   = 5 * time.Minute // Local servers may need to load the model first
+	ProbeTimeout   = 2 * time.Second // Availability checks must not stall startup
+)
+
+// Let callers bound how long a provider may take to respond
+type TimeoutProvider interface {
+	SetTimeout(timeout time.Duration)
+}
+
+// Send provider requests with timeouts, retries and error classification
+type Transport struct {
+	provider      string
+	client        *http.Client
+	mu            sync.Mutex
+	timeout       time.Duration // Time allowed until response headers arrive, per attempt
+	maxRetries    int
+	maxRetryTime  time.Duration // No retry starts once this much time has passed since the first attempt
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+	maxRetryAfter time.Duration // Longer Retry-After values fail fast so fallbacks can run
+}
+
+// Create a transport with default retry settings
+func NewTransport(provider string, timeout time.Duration) *Transport {
+	return &Transport{
+		provider:      provider,
+		client:        http.DefaultClient,
+		timeout:       timeout,
+		maxRetries:    3,
+		maxRetryTime:  time.Minute,
+		baseDelay:     500 * time.Millisecond,
+		maxDelay:      8 * time.Second,
+		maxRetryAfter: 30 * time.Second,
+	}
+}
+
+func (t *Transport) SetTimeout(timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timeout = timeout
+}
+
+func (t *Transport) currentTimeout() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timeout
+}
+
+// Send the request, retrying transient failures; non-2xx responses become *ProviderError
+func (t *Transport) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	start := time.Now()
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req)
+		if err == nil {
+			return resp, nil
+		}
+
+		var providerErr *ProviderError
+		if !errors.As(err, &providerErr) || !providerErr.Retryable() || attempt >= t.maxRetries {
+			return nil, err
+		}
+
+		delay := t.backoff(attempt)
+		if providerErr.Kind == ErrorRateLimit && providerErr.RetryAfter > 0 {
+			if providerErr.RetryAfter > t.maxRetryAfter {
+				return nil, err
+			}
+			delay = providerErr.RetryAfter
+		}
+		if time.Since(start)+delay > t.maxRetryTime {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		// Rewind the body for the next attempt
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// Send a single attempt, bounding the wait for response headers
+func (t *Transport) attempt(req *http.Request) (*http.Response, error) {
+	timeout := t.currentTimeout()
+	ctx, cancel := context.WithCancel(req.Context())
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, cancel)
+	}
+
+	resp, err := t.client.Do(req.WithContext(ctx))
+	if timer != nil {
+		timer.Stop()
+	}
+
+	if err != nil {
+		cancel()
+		if req.Context().Err() != nil {
+			return nil, req.Context().Err()
+		}
+		if ctx.Err() != nil {
+			// Only the timer cancels ctx while the caller's context is still live
+			message := fmt.Sprintf("no response within %v", timeout)
+			return nil, &ProviderError{Provider: t.provider, Kind: ErrorTimeout, Message: message, Err: err}
+		}
+		return nil, &ProviderError{Provider: t.provider, Kind: ErrorNetwork, Message: err.Error(), Err: err}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer cancel()
+		defer resp.Body.Close()
+		return nil, t.statusError(resp)
+	}
+
+	// Keep the context alive while the caller reads the body
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// Build a typed error from a non-2xx response
+func (t *Transport) statusError(resp *http.Response) *ProviderError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	message := errorMessage(body)
+
+	providerErr := &ProviderError{
+		Provider:   t.provider,
+		StatusCode: resp.StatusCode,
+		Message:    message,
+	}
+
+	lower := strings.ToLower(message)
+	switch {
+	case resp.StatusCode == 401 || resp.StatusCode == 403:
+		providerErr.Kind = ErrorAuth
+	case resp.StatusCode == 429:
+		providerErr.Kind = ErrorRateLimit
+		providerErr.RetryAfter = parseRetryAfter(resp.Header)
+	case resp.StatusCode >= 500:
+		providerErr.Kind = ErrorServer
+	case isContextLengthMessage(lower):
+		providerErr.Kind = ErrorContextLength
+	case resp.StatusCode == 404 || isUnsupportedMessage(lower):
+		providerErr.Kind = ErrorUnsupported
+	default:
+		providerErr.Kind = ErrorRequest
+	}
+
+	return providerErr
+}
+
+// Wait between attempts: exponential backoff with jitter
+func (t *Transport) backoff(attempt int) time.Duration {
+	delay := t.baseDelay << attempt
+	if delay > t.maxDelay || delay <= 0 {
+		delay = t.maxDelay
+	}
+	// Spread retries over [delay/2, delay) so clients do not retry in lockstep
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Cancel the attempt's context once the caller is done with the body
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// Parse Retry-After (seconds or HTTP date), plus the millisecond variant some APIs send
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if value, err := strconv.ParseFloat(ms, 64); err == nil && value > 0 {
+			return time.Duration(value * float64(time.Millisecond))
+		}
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// Pull a readable message out of the common error body shapes
+func errorMessage(body []byte) string {
+	var parsed struct {
+		Error   interface{} `json:"error"`
+		Message string      `json:"message"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		switch e := parsed.Error.(type) {
+		case string:
+			return e // Ollama: {"error": "..."}
+		case map[string]interface{}:
+			if message, ok := e["message"].(string); ok {
+				return message // OpenAI, Anthropic, Gemini: {"error": {"message": "..."}}
+			}
+		}
+		if parsed.Message != "" {
+			return parsed.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// Recognise the ways providers report an oversized prompt
+func isContextLengthMessage(message string) bool {
+	for _, marker := range []string{
+		"context_length_exceeded",
+		"maximum context length",
+		"context window",
+		"prompt is too long",
+		"too many tokens",
+		"input token count",
+		"exceeds the context",
+	} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Recognise rejections specific to this provider's model: it is missing, or lacks tools, images or another feature
+func isUnsupportedMessage(message string) bool {
+	for _, marker := range []string{
+		"model not found",
+		"model_not_found",
+		"unknown model",
+		"no such model",
+		"invalid model",
+		"does not exist",
+		"does not support",
+		"not supported",
+		"unsupported",
+	} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
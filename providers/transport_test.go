@@ -0,0 +1,247 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatusErrorKinds(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   ErrorKind
+	}{
+		{401, `{"error":{"message":"invalid api key"}}`, ErrorAuth},
+		{429, `{"error":{"message":"slow down"}}`, ErrorRateLimit},
+		{503, `{"error":{"message":"overloaded"}}`, ErrorServer},
+		{400, `{"error":{"message":"This model's maximum context length is 8192 tokens"}}`, ErrorContextLength},
+		{404, `{"error":"model \"llama3.2\" not found, try pulling it first"}`, ErrorUnsupported},
+		{400, `{"error":{"message":"registry.ollama.ai/library/gemma:2b does not support tools"}}`, ErrorUnsupported},
+		{400, `{"error":{"message":"The model gpt-x does not exist","code":"model_not_found"}}`, ErrorUnsupported},
+		{400, `{"error":{"message":"messages: field required"}}`, ErrorRequest},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+
+		transport := NewTransport("test", DefaultTimeout)
+		transport.maxRetries = 0
+		req, _ := http.NewRequest("POST", server.URL, nil)
+		_, err := transport.Do(req)
+		server.Close()
+
+		if got := ErrorKindOf(err); got != tt.want {
+			t.Errorf("%d %s: kind = %q, want %q", tt.status, tt.body, got, tt.want)
+		}
+	}
+}
+
+// Count requests to a server that answers with the given status and headers
+func statusServer(t *testing.T, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// A transport that retries quickly enough for tests
+func fastTransport() *Transport {
+	transport := NewTransport("test", DefaultTimeout)
+	transport.baseDelay = time.Millisecond
+	transport.maxDelay = 4 * time.Millisecond
+	return transport
+}
+
+func TestRetryCount(t *testing.T) {
+	tests := []struct {
+		status int
+		want   int32
+	}{
+		{503, 4}, // First attempt plus maxRetries
+		{429, 4},
+		{400, 1},
+		{401, 1},
+	}
+
+	for _, tt := range tests {
+		server, calls := statusServer(t, tt.status, nil)
+		req, _ := http.NewRequest("POST", server.URL, strings.NewReader("{}"))
+		if _, err := fastTransport().Do(req); err == nil {
+			t.Fatalf("%d: expected an error", tt.status)
+		}
+		if got := calls.Load(); got != tt.want {
+			t.Errorf("%d: %d attempts, want %d", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestRetryResendsBody(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "{}" {
+			t.Errorf("attempt %d: body = %q", calls.Load()+1, body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(502)
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader("{}"))
+	resp, err := fastTransport().Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+	if got := calls.Load(); got != 3 {
+		t.Errorf("%d attempts, want 3", got)
+	}
+}
+
+func TestRetryElapsedCap(t *testing.T) {
+	server, calls := statusServer(t, 503, nil)
+	transport := fastTransport()
+	transport.baseDelay = 50 * time.Millisecond
+	transport.maxDelay = 50 * time.Millisecond
+	transport.maxRetryTime = 10 * time.Millisecond
+
+	req, _ := http.NewRequest("POST", server.URL, nil)
+	if _, err := transport.Do(req); ErrorKindOf(err) != ErrorServer {
+		t.Fatalf("err = %v, want a server error", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("%d attempts, want 1 once the next wait passes the cap", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	transport := NewTransport("test", DefaultTimeout)
+	for attempt := 0; attempt < 10; attempt++ {
+		want := min(transport.baseDelay<<attempt, transport.maxDelay)
+		for i := 0; i < 20; i++ {
+			if delay := transport.backoff(attempt); delay < want/2 || delay > want {
+				t.Fatalf("attempt %d: delay %v outside [%v, %v]", attempt, delay, want/2, want)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Run("honoured", func(t *testing.T) {
+		server, calls := statusServer(t, 429, http.Header{"Retry-After-Ms": {"60"}})
+		transport := fastTransport()
+		transport.maxRetries = 1
+
+		start := time.Now()
+		req, _ := http.NewRequest("POST", server.URL, nil)
+		_, err := transport.Do(req)
+		if ErrorKindOf(err) != ErrorRateLimit {
+			t.Fatalf("err = %v, want a rate limit", err)
+		}
+		if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+			t.Errorf("retried after %v, want at least the server's 60ms", elapsed)
+		}
+		if got := calls.Load(); got != 2 {
+			t.Errorf("%d attempts, want 2", got)
+		}
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		server, calls := statusServer(t, 429, http.Header{"Retry-After": {"120"}})
+
+		req, _ := http.NewRequest("POST", server.URL, nil)
+		_, err := fastTransport().Do(req)
+		var providerErr *ProviderError
+		if !errors.As(err, &providerErr) || providerErr.RetryAfter != 2*time.Minute {
+			t.Fatalf("err = %v, want a rate limit with a 2m Retry-After", err)
+		}
+		if got := calls.Load(); got != 1 {
+			t.Errorf("%d attempts, want 1 so fallbacks can run", got)
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{"Retry-After": {"2"}}, 2 * time.Second},
+		{http.Header{"Retry-After": {"0.5"}}, 500 * time.Millisecond},
+		{http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"1"}}, 250 * time.Millisecond},
+		{http.Header{"Retry-After": {"soon"}}, 0},
+		{http.Header{"Retry-After": {time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)}}, 0},
+		{http.Header{}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.header, got, tt.want)
+		}
+	}
+
+	future := http.Header{"Retry-After": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}
+	if got := parseRetryAfter(future); got <= 0 || got > time.Minute {
+		t.Errorf("HTTP date: got %v, want up to 1m", got)
+	}
+}
+
+func TestTimeoutIsNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	transport := fastTransport()
+	transport.SetTimeout(20 * time.Millisecond)
+
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader("{}"))
+	_, err := transport.Do(req)
+	if ErrorKindOf(err) != ErrorTimeout {
+		t.Fatalf("err = %v, want a timeout", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("%d attempts, want 1", got)
+	}
+}
+
+func TestCallerCancelIsNotATimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", server.URL, nil)
+	if _, err := fastTransport().Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the caller's deadline", err)
+	}
+}
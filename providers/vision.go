@@ -0,0 +1,196 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Limit image size to keep requests within provider payload limits
+const maxImageBytes = 20 * 1024 * 1024
+
+// Load a local PNG or JPEG file as a base64 data URL
+func LoadImageDataURL(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return imageDataURL(data, path)
+}
+
+// Encode image bytes as a data URL after checking the format
+func imageDataURL(data []byte, source string) (string, error) {
+	if len(data) > maxImageBytes {
+		return "", fmt.Errorf("image %s is larger than %d MB", source, maxImageBytes/1024/1024)
+	}
+
+	mimeType := http.DetectContentType(data)
+	if mimeType != "image/png" && mimeType != "image/jpeg" {
+		return "", fmt.Errorf("unsupported image type %s for %s (use PNG or JPEG)", mimeType, source)
+	}
+
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Attach images to the last user message, converting the conversation to vision messages
+func AttachImages(messages []Message, images []string) ([]VisionMessage, error) {
+	lastUser := -1
+	for i, msg := range messages {
+		if msg.Role == "user" {
+			lastUser = i
+		}
+	}
+	if lastUser == -1 {
+		return nil, fmt.Errorf("no user message to attach images to")
+	}
+
+	var visionMessages []VisionMessage
+	for i, msg := range messages {
+		parts := []ContentPart{{Type: "text", Text: msg.Content}}
+
+		if i == lastUser {
+			for _, image := range images {
+				url := image
+				// Anything that is not already a URL is treated as a local file
+				if !strings.HasPrefix(image, "data:") && !strings.HasPrefix(image, "http://") && !strings.HasPrefix(image, "https://") {
+					dataURL, err := LoadImageDataURL(image)
+					if err != nil {
+						return nil, err
+					}
+					url = dataURL
+				}
+				parts = append(parts, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url}})
+			}
+		}
+
+		visionMessages = append(visionMessages, VisionMessage{Role: msg.Role, Content: parts})
+	}
+
+	return visionMessages, nil
+}
+
+// Resolve an image part to its MIME type and base64 payload, fetching remote URLs
+func resolveImage(ctx context.Context, image *ImageURL) (string, string, error) {
+	if strings.HasPrefix(image.URL, "data:") {
+		header, data, found := strings.Cut(strings.TrimPrefix(image.URL, "data:"), ",")
+		if !found || !strings.HasSuffix(header, ";base64") {
+			return "", "", fmt.Errorf("unsupported data URL: expected base64 encoding")
+		}
+		return strings.TrimSuffix(header, ";base64"), data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", image.URL, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", "", fmt.Errorf("failed to fetch image %s: status %d", image.URL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return "", "", err
+	}
+
+	dataURL, err := imageDataURL(data, image.URL)
+	if err != nil {
+		return "", "", err
+	}
+	return resolveImage(ctx, &ImageURL{URL: dataURL})
+}
+
+// Convert vision messages to the OpenAI content-part format
+func openAIVisionMessages(messages []VisionMessage, options *ChatOptions) []map[string]interface{} {
+	apiMessages := openAIMessages(nil, options)
+	for _, msg := range messages {
+		apiMessages = append(apiMessages, map[string]interface{}{
+			"role":    msg.Role,
+			"content": msg.Content,
+		})
+	}
+	return apiMessages
+}
+
+// Convert vision messages to Gemini contents with inline image data
+func geminiVisionContents(ctx context.Context, messages []VisionMessage) ([]map[string]interface{}, error) {
+	var contents []map[string]interface{}
+
+	for _, msg := range messages {
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+
+		var parts []map[string]interface{}
+		for _, part := range msg.Content {
+			if part.ImageURL == nil {
+				parts = append(parts, map[string]interface{}{"text": part.Text})
+				continue
+			}
+
+			mimeType, data, err := resolveImage(ctx, part.ImageURL)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, map[string]interface{}{
+				"inline_data": map[string]string{
+					"mime_type": mimeType,
+					"data":      data,
+				},
+			})
+		}
+
+		contents = append(contents, map[string]interface{}{
+			"role":  role,
+			"parts": parts,
+		})
+	}
+
+	return contents, nil
+}
+
+// Convert vision messages to Ollama messages with base64 images
+func ollamaVisionMessages(ctx context.Context, messages []VisionMessage, options *ChatOptions) ([]map[string]interface{}, error) {
+	apiMessages := ollamaMessages(nil, options)
+
+	for _, msg := range messages {
+		var text []string
+		var images []string
+
+		for _, part := range msg.Content {
+			if part.ImageURL == nil {
+				text = append(text, part.Text)
+				continue
+			}
+
+			_, data, err := resolveImage(ctx, part.ImageURL)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, data)
+		}
+
+		apiMsg := map[string]interface{}{
+			"role":    msg.Role,
+			"content": strings.Join(text, "\n"),
+		}
+		if len(images) > 0 {
+			apiMsg["images"] = images
+		}
+		apiMessages = append(apiMessages, apiMsg)
+	}
+
+	return apiMessages, nil
+}